@@ -1,3 +1,35 @@
 // 定义配置接口
 // 实现对xml,yaml,ini,json等格式的配置文件的读操作
 package config
+
+import (
+	"time"
+)
+
+// 配置读取接口
+type IConfig interface {
+	Load(filename string, v interface{}) error
+}
+
+// http服务配置
+// 时间使用time.ParseDuration支持的格式比如"5s"，"1m30s"
+type Server struct {
+	ReadTimeout       Duration `json:"read_timeout"`
+	ReadHeaderTimeout Duration `json:"read_header_timeout"`
+	WriteTimeout      Duration `json:"write_timeout"`
+	IdleTimeout       Duration `json:"idle_timeout"`
+	MaxHeaderBytes    int      `json:"max_header_bytes"`
+}
+
+// 支持字符串格式的时间间隔
+type Duration time.Duration
+
+// 实现encoding.TextUnmarshaler接口
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
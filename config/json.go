@@ -0,0 +1,29 @@
+// 读取json格式的配置文件
+package config
+
+import (
+	"os"
+
+	"github.com/pxlh007/doris/internal/json"
+)
+
+// json配置读取器
+type JsonConfig struct{}
+
+// 声明接口的实现对象
+var _ IConfig = JsonConfig{}
+
+// 实现Load接口
+func (j JsonConfig) Load(filename string, v interface{}) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewDecoder(f).Decode(v)
+}
+
+// 读取json配置文件到v中
+func LoadJson(filename string, v interface{}) error {
+	return JsonConfig{}.Load(filename, v)
+}
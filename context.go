@@ -1,13 +1,20 @@
 package doris
 
 import (
-	// "fmt"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
 	"math"
 	"net/http"
 	"net/url"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/pxlh007/doris/binding"
 	"github.com/pxlh007/doris/render"
@@ -26,7 +33,8 @@ type Context struct {
 	fullPath  string                 // 全路径
 	Doris     *Doris                 // 框架对象
 	params    map[string]interface{} // 保存同一个context下的参数（key/value）
-	accepted  []string               // 保存被接受的内容协商类型
+	keys      map[string]interface{} // 请求范围内的键值对存储，和路由参数分开
+	accepted  []acceptItem           // 保存被接受的内容协商类型
 	lock      sync.RWMutex           // 上下文锁
 	// errors   errorMsgs     // 保存同一个context下的所有中间件和主处理函数的错误信息
 }
@@ -54,17 +62,245 @@ func (c *Context) Next() {
 	// 通过Next启动处理链
 	c.index++
 	// 循环逐个执行注册的方法
+	// 返回错误时终止处理链并交给集中式错误处理器
 	for c.index < int8(len(c.handlers)) {
-		c.handlers[c.index](c)
+		if err := c.handlers[c.index](c); err != nil {
+			c.Abort()
+			c.handleError(err)
+		}
 		c.index++
 	}
 }
 
+// 将错误交给集中式错误处理器
+func (c *Context) handleError(err error) {
+	if c.Doris.HTTPErrorHandler != nil {
+		c.Doris.HTTPErrorHandler(err, c)
+		return
+	}
+	c.Doris.DefaultHTTPErrorHandler(err, c)
+}
+
+// 使用查找到的路由执行处理链
+// hostParams为从Host中提取的参数
+func (c *Context) handleRoute(nodev *nodeValue, hostParams map[string]interface{}) {
+	c.handlers = nodev.handlers
+	c.params = SliceToMap(nodev.params, nodev.pvalues)
+	for k, v := range hostParams {
+		c.params[k] = v
+	}
+	c.fullPath = nodev.fullPath
+	c.index = -1 // 默认设置为-1
+	c.Next()     // 执行函数处理链
+}
+
 // 终止处理链
 func (c *Context) Abort() {
 	c.index = abortIndex
 }
 
+// 重置上下文，在放回对象池之前调用
+func (c *Context) reset() {
+	c.handlers = nil
+	c.index = -1
+	c.fullPath = ""
+	c.params = nil
+	c.keys = nil
+	c.accepted = nil
+	c.Request = nil
+}
+
+/************************************/
+/******** 上下文数据存取相关 ************/
+/************************************/
+// 保存键值对，用于在中间件和处理函数之间传递数据
+func (c *Context) Set(key string, value interface{}) {
+	c.lock.Lock()
+	if c.keys == nil {
+		c.keys = make(map[string]interface{})
+	}
+	c.keys[key] = value
+	c.lock.Unlock()
+}
+
+// 获取键值对，exists表示是否存在
+func (c *Context) Get(key string) (value interface{}, exists bool) {
+	c.lock.RLock()
+	value, exists = c.keys[key]
+	c.lock.RUnlock()
+	return
+}
+
+// 获取键值对，不存在时panic
+func (c *Context) MustGet(key string) interface{} {
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+	panic("key \"" + key + "\" does not exist")
+}
+
+// 获取string类型的值，不存在或者类型不符时返回零值
+func (c *Context) GetString(key string) (s string) {
+	if val, ok := c.Get(key); ok && val != nil {
+		s, _ = val.(string)
+	}
+	return
+}
+
+// 获取bool类型的值
+func (c *Context) GetBool(key string) (b bool) {
+	if val, ok := c.Get(key); ok && val != nil {
+		b, _ = val.(bool)
+	}
+	return
+}
+
+// 获取int类型的值
+func (c *Context) GetInt(key string) (i int) {
+	if val, ok := c.Get(key); ok && val != nil {
+		i, _ = val.(int)
+	}
+	return
+}
+
+// 获取int64类型的值
+func (c *Context) GetInt64(key string) (i64 int64) {
+	if val, ok := c.Get(key); ok && val != nil {
+		i64, _ = val.(int64)
+	}
+	return
+}
+
+// 获取uint64类型的值
+func (c *Context) GetUint64(key string) (ui64 uint64) {
+	if val, ok := c.Get(key); ok && val != nil {
+		ui64, _ = val.(uint64)
+	}
+	return
+}
+
+// 获取float64类型的值
+func (c *Context) GetFloat64(key string) (f64 float64) {
+	if val, ok := c.Get(key); ok && val != nil {
+		f64, _ = val.(float64)
+	}
+	return
+}
+
+// 获取time.Time类型的值
+func (c *Context) GetTime(key string) (t time.Time) {
+	if val, ok := c.Get(key); ok && val != nil {
+		t, _ = val.(time.Time)
+	}
+	return
+}
+
+// 获取time.Duration类型的值
+func (c *Context) GetDuration(key string) (d time.Duration) {
+	if val, ok := c.Get(key); ok && val != nil {
+		d, _ = val.(time.Duration)
+	}
+	return
+}
+
+// 获取[]string类型的值
+func (c *Context) GetStringSlice(key string) (ss []string) {
+	if val, ok := c.Get(key); ok && val != nil {
+		ss, _ = val.([]string)
+	}
+	return
+}
+
+// 获取map[string]interface{}类型的值
+func (c *Context) GetStringMap(key string) (sm map[string]interface{}) {
+	if val, ok := c.Get(key); ok && val != nil {
+		sm, _ = val.(map[string]interface{})
+	}
+	return
+}
+
+/************************************/
+/******** context.Context相关 *********/
+/************************************/
+// Context实现了context.Context接口，可以直接传递给数据库、http客户端等库
+// 客户端断开连接或者超时时Done会被关闭
+// 注意：处理函数返回后Context会被重置并放回对象池，不能在处理函数之外继续使用
+// 交给其他goroutine或者可能在处理函数返回后才读取Done的库时，需要传递c.Copy()
+var _ context.Context = &Context{}
+
+// 复制当前上下文，副本不会被放回对象池，可以在处理函数返回后安全使用
+// 副本保留请求对象、路由参数和键值对，但不能用于写响应和执行处理链
+// 调用方式：cc := c.Copy(); go func() { db.ExecContext(cc, query) }()
+func (c *Context) Copy() *Context {
+	cp := &Context{
+		Response: &Response{},
+		Request:  c.Request,
+		index:    abortIndex,
+		fullPath: c.fullPath,
+		Doris:    c.Doris,
+	}
+	if c.params != nil {
+		cp.params = make(map[string]interface{}, len(c.params))
+		for k, v := range c.params {
+			cp.params[k] = v
+		}
+	}
+	c.lock.RLock()
+	if c.keys != nil {
+		cp.keys = make(map[string]interface{}, len(c.keys))
+		for k, v := range c.keys {
+			cp.keys[k] = v
+		}
+	}
+	c.lock.RUnlock()
+	return cp
+}
+
+// 返回请求的截止时间
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	if c.Request == nil {
+		return
+	}
+	return c.Request.Context().Deadline()
+}
+
+// 返回请求被取消或者超时时关闭的通道
+func (c *Context) Done() <-chan struct{} {
+	if c.Request == nil {
+		return nil
+	}
+	return c.Request.Context().Done()
+}
+
+// 返回请求被取消的原因
+func (c *Context) Err() error {
+	if c.Request == nil {
+		return nil
+	}
+	return c.Request.Context().Err()
+}
+
+// 先从键值对存储中查找string类型的key，再从请求的context中查找
+func (c *Context) Value(key interface{}) interface{} {
+	if k, ok := key.(string); ok {
+		if val, exists := c.Get(k); exists {
+			return val
+		}
+	}
+	if c.Request == nil {
+		return nil
+	}
+	return c.Request.Context().Value(key)
+}
+
+// 给当前请求设置超时时间，超时后Done被关闭
+// 调用方式：defer c.WithTimeout(3 * time.Second)()
+func (c *Context) WithTimeout(d time.Duration) context.CancelFunc {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+	c.Request = c.Request.WithContext(ctx)
+	return cancel
+}
+
 /************************************/
 /******** 参数绑定/获取相关 ************/
 /************************************/
@@ -123,9 +359,111 @@ func (c *Context) DefaultFormParm(param string, def interface{}) string {
 }
 
 // 处理静态文件方法
-func (c *Context) File(filepath string) {
-	//
+// 基于http.ServeContent支持Range、If-Modified-Since以及ETag
+// 文件不存在时返回ErrNotFound，没有权限时返回ErrForbidden
+func (c *Context) File(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fileError(err)
+	}
+	defer f.Close()
+	return c.serveContent(f, "")
+}
 
+// 从指定的文件系统中读取文件并响应
+func (c *Context) FileFromFS(file string, fs http.FileSystem) error {
+	f, err := fs.Open(file)
+	if err != nil {
+		return fileError(err)
+	}
+	defer f.Close()
+	return c.serveContent(f, "")
+}
+
+// 以附件的形式响应文件，浏览器会提示下载
+// filename支持UTF-8字符，按照RFC 6266设置Content-Disposition
+func (c *Context) FileAttachment(file, filename string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fileError(err)
+	}
+	defer f.Close()
+	return c.serveContent(f, contentDisposition("attachment", filename))
+}
+
+// 实际响应文件内容
+// disposition不为空时设置Content-Disposition，文件无法响应时不设置，避免错误信息被当作文件下载
+func (c *Context) serveContent(f http.File, disposition string) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	// 不处理目录
+	if fi.IsDir() {
+		return ErrNotFound
+	}
+	// 使用修改时间和大小生成强ETag，使If-Range可以用于断点续传
+	// 没有修改时间（比如embed.FS）时无法区分内容变化，不设置ETag
+	header := c.Response.Header()
+	if disposition != "" {
+		header.Set("Content-Disposition", disposition)
+	}
+	if header.Get("Etag") == "" && !fi.ModTime().IsZero() {
+		header.Set("Etag", fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()))
+	}
+	http.ServeContent(c.Response, c.Request, fi.Name(), fi.ModTime(), f)
+	return nil
+}
+
+// 将打开文件的错误转换为http错误
+func fileError(err error) error {
+	switch {
+	case os.IsNotExist(err):
+		return ErrNotFound
+	case os.IsPermission(err):
+		return ErrForbidden
+	}
+	return err
+}
+
+// 生成RFC 6266格式的Content-Disposition
+// 非ASCII字符使用filename*参数传递，filename参数作为兼容旧客户端的回退
+func contentDisposition(dispositionType, filename string) string {
+	var fallback, encoded strings.Builder
+	ascii := true
+	for _, r := range filename {
+		switch {
+		case r >= utf8.RuneSelf || r < 0x20 || r == 0x7f:
+			ascii = false
+			fallback.WriteByte('_')
+		case r == '"' || r == '\\':
+			fallback.WriteByte('\\')
+			fallback.WriteRune(r)
+		default:
+			fallback.WriteRune(r)
+		}
+	}
+	if ascii {
+		return fmt.Sprintf(`%s; filename="%s"`, dispositionType, fallback.String())
+	}
+	// RFC 5987中的attr-char原样保留，其余字节百分号编码
+	for i := 0; i < len(filename); i++ {
+		if b := filename[i]; isAttrChar(b) {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, dispositionType, fallback.String(), encoded.String())
+}
+
+// 判断是否为RFC 5987中的attr-char
+func isAttrChar(b byte) bool {
+	switch {
+	case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", b) >= 0
 }
 
 // 根据参数名获取参数值
@@ -133,23 +471,156 @@ func (c *Context) Param(name string) interface{} {
 	return c.params[name]
 }
 
+// 获取字符串类型的参数值，不存在时返回空字符串
+func (c *Context) ParamString(name string) string {
+	v, _ := c.params[name].(string)
+	return v
+}
+
+// 获取int类型的参数值，通常和:name<int>约束一起使用
+func (c *Context) ParamInt(name string) (int, error) {
+	v, err := c.paramValue(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(v)
+}
+
+// 获取int64类型的参数值
+func (c *Context) ParamInt64(name string) (int64, error) {
+	v, err := c.paramValue(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// 获取uint64类型的参数值
+func (c *Context) ParamUint64(name string) (uint64, error) {
+	v, err := c.paramValue(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(v, 10, 64)
+}
+
+// 获取float64类型的参数值
+func (c *Context) ParamFloat64(name string) (float64, error) {
+	v, err := c.paramValue(name)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(v, 64)
+}
+
+// 获取bool类型的参数值
+func (c *Context) ParamBool(name string) (bool, error) {
+	v, err := c.paramValue(name)
+	if err != nil {
+		return false, err
+	}
+	return strconv.ParseBool(v)
+}
+
+// 获取参数的原始值，不存在时返回错误
+func (c *Context) paramValue(name string) (string, error) {
+	v, ok := c.params[name].(string)
+	if !ok {
+		return "", fmt.Errorf("param '%s' not found", name)
+	}
+	return v, nil
+}
+
+// 根据路由名称和参数生成url
+func (c *Context) URLFor(name string, params ...interface{}) (string, error) {
+	return c.Doris.URL(name, params...)
+}
+
 /************************************/
 /******** 响应渲染相关 ****************/
 /************************************/
+// 渲染时使用的缓冲区，超过maxRenderBufferSize的缓冲区不放回池中
+var renderBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// 放回缓冲池的缓冲区的最大容量，防止偶尔的大响应长期占用内存
+const maxRenderBufferSize = 64 << 10
+
 // 渲染函数
+// 响应体先编码到缓冲区，成功后才提交状态码和响应头
+// 编码失败时终止处理链并交给集中式错误处理器，客户端得到完整的错误响应
+// 流式渲染器（比如SSE和Reader）不使用缓冲，直接写入响应
 func (c *Context) render(code int, r render.IRender) {
-	r.WriteContentType(c.Response.Writer) // 设置contentType
-	c.Status(code)                        // 设置status码
-	if !bodyAllowedCode(code) {           // 非允许的code直接返回
+	if sr, ok := r.(render.StreamRender); ok && sr.Streaming() {
+		c.renderStream(code, r)
+		return
+	}
+	if !bodyAllowedCode(code) { // 非允许的code直接返回
+		r.WriteContentType(c.Response)
+		c.Status(code)
 		c.Response.WriteHeaderNow()
 		return
 	}
-	err := r.Render(c.Response.Writer)
-	if err != nil {
-		panic(err)
+
+	buf := renderBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxRenderBufferSize {
+			renderBufferPool.Put(buf)
+		}
+	}()
+	if err := r.Render(bufferedWriter{header: c.Response.Header(), buf: buf}); err != nil {
+		c.renderError(err)
+		return
+	}
+	r.WriteContentType(c.Response) // 设置contentType
+	c.Status(code)                 // 设置status码
+	c.Response.Write(buf.Bytes())
+}
+
+// 直接写入响应的渲染，用于流式渲染器
+func (c *Context) renderStream(code int, r render.IRender) {
+	r.WriteContentType(c.Response)
+	c.Status(code)
+	if !bodyAllowedCode(code) {
+		c.Response.WriteHeaderNow()
+		return
+	}
+	if err := r.Render(c.Response); err != nil {
+		c.renderError(err)
 	}
 }
 
+// 渲染失败时终止处理链并交给集中式错误处理器
+// 流式渲染已经开始写入响应时，错误处理器只能记录日志
+func (c *Context) renderError(err error) {
+	c.Abort()
+	c.handleError(err)
+}
+
+// 渲染时使用的响应对象
+// 响应头直接写入Response，响应体先写入缓冲区，状态码由render统一提交
+type bufferedWriter struct {
+	header http.Header
+	buf    *bytes.Buffer
+}
+
+// 返回Response的响应头
+func (w bufferedWriter) Header() http.Header {
+	return w.header
+}
+
+// 写入缓冲区
+func (w bufferedWriter) Write(data []byte) (int, error) {
+	return w.buf.Write(data)
+}
+
+// 状态码由render统一提交
+func (w bufferedWriter) WriteHeader(int) {}
+
 // 输出json格式
 func (c *Context) Json(code int, obj interface{}) {
 	c.render(code, render.Json{Data: obj})
@@ -180,9 +651,98 @@ func (c *Context) Xml(code int, obj interface{}) {
 	c.render(code, render.Xml{Data: obj})
 }
 
-// 输出html格式
-func (c *Context) Html() {
+// 输出yaml格式
+func (c *Context) Yaml(code int, obj interface{}) {
+	c.render(code, render.Yaml{Data: obj})
+}
+
+// 重定向到location，code必须是3xx或者201
+// 调用方式：return c.Redirect(302, "/login")
+func (c *Context) Redirect(code int, location string) error {
+	if !render.IsRedirectCode(code) {
+		return fmt.Errorf("cannot redirect with status code %d", code)
+	}
+	// 状态码和Location头由http.Redirect直接写入，不经过render的缓冲
+	// 304等不允许响应体的状态码同样需要Location头，所以总是执行渲染
+	c.Status(code)
+	r := render.Redirect{Code: code, Request: c.Request, Location: location}
+	if err := r.Render(c.Response); err != nil {
+		c.renderError(err)
+	}
+	return nil
+}
+
+// 向客户端推送一个服务端事件，data为string和[]byte时原样输出，其他类型编码为json
+// 通常在Stream中调用
+func (c *Context) SSEvent(name string, data interface{}) {
+	c.SSE(render.SSE{Event: name, Data: data})
+}
+
+// 推送完整的服务端事件，可以设置事件的id和客户端的重连间隔
+// 调用方式：c.SSE(render.SSE{Id: "42", Event: "message", Retry: 3000, Data: msg})
+func (c *Context) SSE(event render.SSE) {
+	c.render(c.Response.Status(), event) // 沿用已经设置的状态码，响应头提交之后不再修改
+}
+
+// 流式输出响应，step返回false或者客户端断开连接时结束
+// 每次调用step之后都会刷新缓冲区，返回值表示是否因为客户端断开而结束
+// 调用方式：c.Stream(func(w io.Writer) bool { c.SSEvent("message", <-messages); return true })
+func (c *Context) Stream(step func(w io.Writer) bool) bool {
+	done := c.Request.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+		}
+		keepOpen := step(c.Response)
+		c.Response.Flush()
+		if !keepOpen {
+			return false
+		}
+	}
+}
+
+// 只返回状态码不返回响应体
+func (c *Context) NoContent(code int) {
+	c.render(code, render.NoContent{})
+}
+
+// 输出指定类型的原始数据
+func (c *Context) Data(code int, contentType string, data []byte) {
+	c.render(code, render.Data{ContentType: contentType, Data: data})
+}
 
+// 从reader中流式输出数据，contentLength小于0时表示未知长度
+// extraHeaders用于设置额外的响应头，比如Content-Disposition
+func (c *Context) DataFromReader(code int, contentLength int64, contentType string, reader io.Reader, extraHeaders map[string]string) {
+	c.render(code, render.Reader{
+		ContentType:   contentType,
+		ContentLength: contentLength,
+		Reader:        reader,
+		Headers:       extraHeaders,
+	})
+}
+
+// 使用Doris.HTMLRender渲染name指定的html模板
+// 调试模式下每次渲染前重新加载模板，修改模板后无需重启
+// 调用方式：return c.HTML(200, "users/show.html", doris.D{"user": user})
+func (c *Context) HTML(code int, name string, data interface{}) error {
+	engine := c.Doris.HTMLRender
+	if engine == nil {
+		return fmt.Errorf("html render is not set, call LoadHTMLGlob or LoadHTMLFS first")
+	}
+	if c.Doris.Debug {
+		if err := engine.Load(); err != nil {
+			return err
+		}
+	}
+	r, err := engine.Instance(name, data)
+	if err != nil {
+		return err
+	}
+	c.render(code, r)
+	return nil
 }
 
 // 检查传入的status是否是http包允许的
@@ -200,11 +760,9 @@ func bodyAllowedCode(code int) bool {
 }
 
 // 设置响应头状态码行
+// 状态码在第一次写入响应体或者处理链结束时才会提交
 func (c *Context) Status(code int) {
-	// 设置封装后的status
-	c.Response.status = code
-	// 写实际响应头status
-	c.Response.Writer.WriteHeader(code)
+	c.Response.WriteHeader(code)
 }
 
 /************************************/
@@ -214,10 +772,10 @@ func (c *Context) Status(code int) {
 func (c *Context) SetResponseHeader(key, value string) {
 	// 传递为空自动清除
 	if value == "" {
-		c.Response.Writer.Header().Del(key)
+		c.Response.Header().Del(key)
 		return
 	}
-	c.Response.Writer.Header().Set(key, value)
+	c.Response.Header().Set(key, value)
 }
 
 // 设置任意请求头信息
@@ -298,3 +856,179 @@ func (c *Context) Cookie(key string) (val string, err error) {
 /************************************/
 /******** 内容协商相关 ****************/
 /************************************/
+// 常用的MIME类型
+const (
+	MIMEJSON  = "application/json"
+	MIMEXML   = "application/xml"
+	MIMEXML2  = "text/xml"
+	MIMEHTML  = "text/html"
+	MIMEYAML  = "application/x-yaml"
+	MIMEYAML2 = "application/yaml"
+	MIMEPlain = "text/plain"
+)
+
+// 内容协商的配置
+// 根据Accept头从设置了数据的格式中选择一种进行渲染
+type Negotiate struct {
+	Offered  []string    // 提供的MIME类型，为空时按JSON、XML、YAML、HTML的顺序从设置了数据的格式中推导
+	// Data只会推导出JSON和YAML，map等类型无法编码为xml，需要xml时请设置XML或者在Offered中显式提供
+	JSON     interface{} // json格式的数据
+	XML      interface{} // xml格式的数据
+	YAML     interface{} // yaml格式的数据
+	HTML     interface{} // html模板的数据
+	HTMLName string      // html模板名，为空时不提供html格式
+	Data     interface{} // 对应格式未设置数据时使用的通用数据
+}
+
+// Accept头中的单个媒体类型
+type acceptItem struct {
+	mediaType string
+	q         float64
+}
+
+// 根据Accept头从offered中选择客户端最希望接收的MIME类型
+// Accept头为空时返回第一个提供的类型，没有可接受的类型时返回空字符串
+// 调用方式：switch c.NegotiateFormat(doris.MIMEJSON, doris.MIMEHTML) {...}
+func (c *Context) NegotiateFormat(offered ...string) string {
+	assert1(len(offered) > 0, "you must provide at least one offer")
+	if c.accepted == nil {
+		header := c.Request.Header.Get("Accept")
+		if header == "" {
+			return offered[0]
+		}
+		c.accepted = parseAccept(header)
+	}
+	// 每个提供的类型由匹配的最具体的媒体类型决定其q值
+	// q值相同时优先选择匹配排在前面的媒体类型的类型
+	best, bestQ, bestRank := "", 0.0, len(c.accepted)
+	for _, offer := range offered {
+		rank := -1
+		for i, accepted := range c.accepted {
+			if matchMediaType(accepted.mediaType, offer) &&
+				(rank == -1 || mediaSpecificity(accepted.mediaType) > mediaSpecificity(c.accepted[rank].mediaType)) {
+				rank = i
+			}
+		}
+		if rank == -1 {
+			continue
+		}
+		q := c.accepted[rank].q
+		if q > bestQ || (q == bestQ && q > 0 && rank < bestRank) {
+			best, bestQ, bestRank = offer, q, rank
+		}
+	}
+	return best
+}
+
+// 覆盖从Accept头解析出的可接受类型，按优先级从高到低排列
+func (c *Context) SetAccepted(formats ...string) {
+	c.accepted = make([]acceptItem, len(formats))
+	for i, format := range formats {
+		c.accepted[i] = acceptItem{mediaType: strings.ToLower(format), q: 1}
+	}
+}
+
+// 根据Accept头选择格式并渲染，没有可接受的格式时返回ErrNotAcceptable
+// 调用方式：return c.Negotiate(200, doris.Negotiate{JSON: user, HTMLName: "user.html", HTML: user})
+func (c *Context) Negotiate(code int, config Negotiate) error {
+	offered := config.Offered
+	if len(offered) == 0 {
+		if config.JSON != nil || config.Data != nil {
+			offered = append(offered, MIMEJSON)
+		}
+		if config.XML != nil {
+			offered = append(offered, MIMEXML, MIMEXML2)
+		}
+		if config.YAML != nil || config.Data != nil {
+			offered = append(offered, MIMEYAML, MIMEYAML2)
+		}
+		if config.HTMLName != "" {
+			offered = append(offered, MIMEHTML)
+		}
+	}
+	if len(offered) == 0 {
+		return ErrNotAcceptable
+	}
+
+	switch c.NegotiateFormat(offered...) {
+	case MIMEJSON:
+		c.Json(code, orData(config.JSON, config.Data))
+	case MIMEXML, MIMEXML2:
+		c.Xml(code, orData(config.XML, config.Data))
+	case MIMEYAML, MIMEYAML2:
+		c.Yaml(code, orData(config.YAML, config.Data))
+	case MIMEHTML:
+		return c.HTML(code, config.HTMLName, orData(config.HTML, config.Data))
+	default:
+		return ErrNotAcceptable
+	}
+	return nil
+}
+
+// 对应格式未设置数据时使用通用数据
+func orData(data, fallback interface{}) interface{} {
+	if data != nil {
+		return data
+	}
+	return fallback
+}
+
+// 解析Accept头，返回按q值从高到低排列的媒体类型，q值相同时更具体的排在前面
+// q值为0表示不接受，保留下来用于排除被更宽泛的类型匹配的类型
+func parseAccept(header string) []acceptItem {
+	items := make([]acceptItem, 0, 4)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+		if mediaType == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.ToLower(strings.TrimSpace(key)) != "q" {
+				continue
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || v < 0 || v > 1 {
+				v = 0
+			}
+			q = v
+		}
+		items = append(items, acceptItem{mediaType: mediaType, q: q})
+	}
+	// q值和具体程度都相同时保持原有顺序
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].q != items[j].q {
+			return items[i].q > items[j].q
+		}
+		return mediaSpecificity(items[i].mediaType) > mediaSpecificity(items[j].mediaType)
+	})
+	return items
+}
+
+// 媒体类型的具体程度：*/*为0，type/*为1，type/subtype为2
+func mediaSpecificity(mediaType string) int {
+	switch {
+	case mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(mediaType, "/*"):
+		return 1
+	}
+	return 2
+}
+
+// 判断可接受的类型是否匹配提供的类型，支持type/*和*/*通配
+func matchMediaType(accepted, offer string) bool {
+	offer = strings.ToLower(offer)
+	if i := strings.IndexByte(offer, ';'); i >= 0 {
+		offer = strings.TrimSpace(offer[:i])
+	}
+	switch {
+	case accepted == "*/*" || accepted == offer:
+		return true
+	case strings.HasSuffix(accepted, "/*"):
+		return strings.HasPrefix(offer, accepted[:len(accepted)-1])
+	}
+	return false
+}
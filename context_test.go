@@ -0,0 +1,392 @@
+package doris
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pxlh007/doris/render"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	offered := []string{MIMEJSON, MIMEXML, MIMEHTML}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", MIMEJSON},
+		{"application/xml", MIMEXML},
+		{"text/html, application/json", MIMEHTML},
+		{"application/json;q=0.5, text/html", MIMEHTML},
+		{"*/*", MIMEJSON},
+		{"*/*, application/json;q=0", MIMEXML},
+		{"application/json;q=0, */*", MIMEXML},
+		{"*/*;q=0.1, text/*", MIMEHTML},
+		{"text/*;q=0, */*", MIMEJSON},
+		{"text/*, */*;q=0.5, text/html;q=0", MIMEJSON},
+		{"*/*, application/xml", MIMEXML},
+		{"application/*, application/xml", MIMEXML},
+		{"application/json;q=0, application/xml;q=0, text/html;q=0", ""},
+		{"image/png", ""},
+		{"application/json;q=abc, text/html;q=0.2", MIMEHTML},
+		{"APPLICATION/XML;Q=0.9", MIMEXML},
+	}
+	for _, tt := range tests {
+		c := &Context{Request: httptest.NewRequest(http.MethodGet, "/", nil)}
+		if tt.accept != "" {
+			c.Request.Header.Set("Accept", tt.accept)
+		}
+		if got := c.NegotiateFormat(offered...); got != tt.want {
+			t.Errorf("Accept %q: got %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestParseAccept(t *testing.T) {
+	items := parseAccept("*/*;q=0.8, text/*, text/html;level=1, application/json;q=0")
+	want := []acceptItem{
+		{"text/html", 1},
+		{"text/*", 1},
+		{"*/*", 0.8},
+		{"application/json", 0},
+	}
+	if len(items) != len(want) {
+		t.Fatalf("got %v, want %v", items, want)
+	}
+	for i := range want {
+		if items[i] != want[i] {
+			t.Errorf("item %d: got %v, want %v", i, items[i], want[i])
+		}
+	}
+}
+
+func TestSetAccepted(t *testing.T) {
+	c := &Context{Request: httptest.NewRequest(http.MethodGet, "/", nil)}
+	c.Request.Header.Set("Accept", "application/json")
+	c.SetAccepted(MIMEHTML, "*/*")
+	if got := c.NegotiateFormat(MIMEJSON, MIMEHTML); got != MIMEHTML {
+		t.Errorf("got %q, want %q", got, MIMEHTML)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	type item struct{ N int }
+	tests := []struct {
+		accept string
+		config Negotiate
+		code   int
+		ct     string
+	}{
+		{"application/json", Negotiate{Data: D{"n": 1}}, 200, MIMEJSON},
+		{"application/x-yaml", Negotiate{Data: D{"n": 1}}, 200, MIMEYAML},
+		{"application/xml", Negotiate{Data: D{"n": 1}}, http.StatusNotAcceptable, MIMEJSON},
+		{"application/xml, application/json;q=0.5", Negotiate{Data: D{"n": 1}}, 200, MIMEJSON},
+		{"application/xml", Negotiate{XML: item{1}, Data: D{"n": 1}}, 200, MIMEXML},
+		{"text/html", Negotiate{JSON: D{"n": 1}}, http.StatusNotAcceptable, MIMEJSON},
+	}
+	for _, tt := range tests {
+		d := New()
+		config := tt.config
+		d.GET("/", func(c *Context) error {
+			return c.Negotiate(200, config)
+		})
+		w := performRequest(d, http.MethodGet, "/", "Accept", tt.accept)
+		if w.Code != tt.code || !strings.HasPrefix(w.Header().Get("Content-Type"), tt.ct) {
+			t.Errorf("Accept %q: got %d %q, want %d %q", tt.accept, w.Code, w.Header().Get("Content-Type"), tt.code, tt.ct)
+		}
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	tests := []struct {
+		code     int
+		location string
+		want     int
+		wantLoc  string
+	}{
+		{http.StatusFound, "/login", http.StatusFound, "/login"},
+		{http.StatusMovedPermanently, "next", http.StatusMovedPermanently, "/a/next"},
+		{http.StatusCreated, "/items/1", http.StatusCreated, "/items/1"},
+		{http.StatusNotModified, "/cached", http.StatusNotModified, "/cached"},
+		{http.StatusOK, "/login", http.StatusInternalServerError, ""},
+	}
+	for _, tt := range tests {
+		d := New()
+		code, location := tt.code, tt.location
+		d.GET("/a/b", func(c *Context) error {
+			return c.Redirect(code, location)
+		})
+		w := performRequest(d, http.MethodGet, "/a/b")
+		if w.Code != tt.want || w.Header().Get("Location") != tt.wantLoc {
+			t.Errorf("Redirect(%d, %q): got %d %q, want %d %q", tt.code, tt.location,
+				w.Code, w.Header().Get("Location"), tt.want, tt.wantLoc)
+		}
+	}
+}
+
+func TestContextKeys(t *testing.T) {
+	d := New()
+	d.Use(func(c *Context) error {
+		if c.QueryParam("set") != "" {
+			c.Set("id", "from-key")
+			c.Set("count", 3)
+			c.Set("ratio", 0.5)
+			c.Set("timeout", 2*time.Second)
+			c.Set("tags", []string{"a", "b"})
+		}
+		return nil
+	})
+	d.GET("/users/:id", func(c *Context) error {
+		_, exists := c.Get("id")
+		c.String(http.StatusOK, "%v|%v|%q|%d|%v|%v|%v|%d|%v",
+			c.Param("id"), exists, c.GetString("id"), c.GetInt("count"), c.GetFloat64("ratio"),
+			c.GetDuration("timeout"), c.GetStringSlice("tags"),
+			c.GetInt64("count"), c.GetBool("id")) // 类型不符时返回零值
+		return nil
+	})
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/users/7?set=1", `7|true|"from-key"|3|0.5|2s|[a b]|0|false`},
+		// 同一个池中的Context不会带上前一个请求的数据
+		{"/users/8", `8|false|""|0|0|0s|[]|0|false`},
+		{"/users/9?set=1", `9|true|"from-key"|3|0.5|2s|[a b]|0|false`},
+		{"/users/10", `10|false|""|0|0|0s|[]|0|false`},
+	}
+	for _, tt := range tests {
+		w := performRequest(d, http.MethodGet, tt.path)
+		if got := w.Body.String(); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.path, got, tt.want)
+		}
+	}
+
+	// reset之后不保留任何请求数据
+	c := d.pool.Get().(*Context)
+	c.Set("k", 1)
+	c.params = map[string]interface{}{"id": "1"}
+	c.SetAccepted(MIMEJSON)
+	c.reset()
+	if _, exists := c.Get("k"); exists || c.Param("id") != nil || c.accepted != nil {
+		t.Errorf("reset kept request data: keys %v params %v accepted %v", c.keys, c.params, c.accepted)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("MustGet on a missing key should panic")
+		}
+	}()
+	c.MustGet("k")
+}
+
+func TestSSE(t *testing.T) {
+	d := New()
+	d.GET("/events", func(c *Context) error {
+		c.SSE(render.SSE{Id: "42", Event: "update", Retry: 3000, Data: "hello"})
+		c.SSEvent("", D{"n": 1})
+		return nil
+	})
+	w := performRequest(d, http.MethodGet, "/events")
+	want := "id: 42\nevent: update\nretry: 3000\ndata: hello\n\ndata: {\"n\":1}\n\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("got Content-Type %q", ct)
+	}
+}
+
+func TestContextCopyOutlivesHandler(t *testing.T) {
+	type ctxKey struct{}
+	d := New()
+	result := make(chan string, 1)
+	d.GET("/users/:id", func(c *Context) error {
+		c.Set("user", "doris")
+		cc := c.Copy()
+		go func() {
+			<-cc.Done()
+			result <- fmt.Sprintf("%v %v %v %v", cc.Value("user"), cc.Value(ctxKey{}), cc.Err(), cc.params["id"])
+		}()
+		return nil
+	})
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "v"))
+	req := httptest.NewRequest(http.MethodGet, "/users/7", nil).WithContext(ctx)
+	d.ServeHTTP(httptest.NewRecorder(), req)
+	// 上下文放回对象池后被其他请求复用
+	performRequest(d, http.MethodGet, "/users/8")
+	cancel()
+	if got, want := <-result, "doris v context canceled 7"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFileIfRange(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(file, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d := New()
+	d.GET("/data", func(c *Context) error {
+		return c.File(file)
+	})
+	w := performRequest(d, http.MethodGet, "/data")
+	etag := w.Header().Get("Etag")
+	if etag == "" || strings.HasPrefix(etag, "W/") {
+		t.Fatalf("got ETag %q, want a strong ETag", etag)
+	}
+
+	tests := []struct {
+		ifRange string
+		code    int
+		body    string
+	}{
+		{etag, http.StatusPartialContent, "2345"},
+		{`"other"`, http.StatusOK, "0123456789"},
+	}
+	for _, tt := range tests {
+		w := performRequest(d, http.MethodGet, "/data", "Range", "bytes=2-5", "If-Range", tt.ifRange)
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("If-Range %s: got %d %q, want %d %q", tt.ifRange, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	tests := []struct {
+		name   string
+		debug  bool
+		render func(c *Context)
+		body   string
+	}{
+		{"json", false, func(c *Context) { c.Json(http.StatusCreated, D{"ch": make(chan int)}) }, `"message":"Internal Server Error"`},
+		{"json debug", true, func(c *Context) { c.Json(http.StatusCreated, D{"ch": make(chan int)}) }, "unsupported type: chan int"},
+		{"xml", false, func(c *Context) { c.Xml(http.StatusCreated, map[string]int{"a": 1}) }, `"code":500`},
+		{"yaml", false, func(c *Context) { c.Yaml(http.StatusCreated, D{"f": func() {}}) }, `"code":500`},
+	}
+	for _, tt := range tests {
+		d := New()
+		d.Debug = tt.debug
+		after := false
+		d.GET("/render", func(c *Context) error {
+			c.SetResponseHeader("X-Before", "kept")
+			tt.render(c)
+			return nil
+		}, func(c *Context) error {
+			after = true
+			return nil
+		})
+		w := performRequest(d, http.MethodGet, "/render")
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("%s: got status %d, want 500", tt.name, w.Code)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("%s: got Content-Type %q", tt.name, ct)
+		}
+		if !strings.Contains(w.Body.String(), tt.body) || strings.Count(w.Body.String(), "{") != 1 {
+			t.Errorf("%s: got body %q, want a single error response containing %q", tt.name, w.Body.String(), tt.body)
+		}
+		if w.Header().Get("X-Before") != "kept" {
+			t.Errorf("%s: headers set before rendering were lost", tt.name)
+		}
+		if after {
+			t.Errorf("%s: handler chain continued after a render error", tt.name)
+		}
+	}
+
+	// 自定义的错误处理器收到渲染错误
+	d := New()
+	var got error
+	d.HTTPErrorHandler = func(err error, c *Context) {
+		got = err
+		c.String(http.StatusTeapot, "custom")
+	}
+	d.GET("/render", func(c *Context) error {
+		c.Json(http.StatusOK, make(chan int))
+		return nil
+	})
+	w := performRequest(d, http.MethodGet, "/render")
+	if got == nil || w.Code != http.StatusTeapot || w.Body.String() != "custom" {
+		t.Errorf("got error %v, status %d, body %q", got, w.Code, w.Body.String())
+	}
+}
+
+func TestFileAttachment(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "data.txt"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d := New()
+	d.GET("/download/:name", func(c *Context) error {
+		return c.FileAttachment(filepath.Join(dir, c.params["name"].(string)), "报告.txt")
+	})
+	tests := []struct {
+		name        string
+		code        int
+		disposition string
+	}{
+		{"data.txt", http.StatusOK, `attachment; filename="__.txt"; filename*=UTF-8''%E6%8A%A5%E5%91%8A.txt`},
+		{"missing.txt", http.StatusNotFound, ""},
+		{".", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := performRequest(d, http.MethodGet, "/download/"+tt.name)
+		if w.Code != tt.code || w.Header().Get("Content-Disposition") != tt.disposition {
+			t.Errorf("%s: got %d with Content-Disposition %q, want %d %q",
+				tt.name, w.Code, w.Header().Get("Content-Disposition"), tt.code, tt.disposition)
+		}
+	}
+}
+
+func TestLoadHTMLGlob(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.Mkdir("templates", 0755); err != nil {
+		t.Fatal(err)
+	}
+	page := filepath.Join("templates", "index.html")
+	if err := os.WriteFile(page, []byte(`v1 {{shout .name}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 非当前目录下的相对路径返回明确的错误
+	for _, pattern := range []string{filepath.Join(dir, "templates", "*.html"), "../*.html"} {
+		if err := New().LoadHTMLGlob(pattern); err == nil || !strings.Contains(err.Error(), "relative path under the working directory") {
+			t.Errorf("%s: got error %v", pattern, err)
+		}
+	}
+
+	for _, debug := range []bool{false, true} {
+		d := New()
+		d.Debug = debug
+		d.FuncMap = template.FuncMap{"shout": func(s string) string { return strings.ToUpper(s) + "!" }}
+		if err := d.LoadHTMLGlob("./templates/*.html"); err != nil {
+			t.Fatal(err)
+		}
+		d.GET("/", func(c *Context) error {
+			return c.HTML(http.StatusOK, "templates/index.html", D{"name": "doris"})
+		})
+		if w := performRequest(d, http.MethodGet, "/"); w.Body.String() != "v1 DORIS!" || w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+			t.Errorf("debug=%v: got %q (%s)", debug, w.Body.String(), w.Header().Get("Content-Type"))
+		}
+
+		// 调试模式下修改模板后无需重新加载
+		if err := os.WriteFile(page, []byte(`v2 {{shout .name}}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+		want := "v1 DORIS!"
+		if debug {
+			want = "v2 DORIS!"
+		}
+		if w := performRequest(d, http.MethodGet, "/"); w.Body.String() != want {
+			t.Errorf("debug=%v: after editing got %q, want %q", debug, w.Body.String(), want)
+		}
+		os.WriteFile(page, []byte(`v1 {{shout .name}}`), 0644)
+	}
+}
@@ -3,22 +3,24 @@ package doris
 import (
 	//"bytes"
 	//"crypto/tls"
-	//"errors"
+	"errors"
 	"fmt"
 	//"io"
 	//"io/ioutil"
 	//"net"
+	"html/template"
+	"io/fs"
 	"net/http"
 	//"net/url"
 	//"path"
-	//"path/filepath"
+	"path/filepath"
 	//"reflect"
 	//"runtime"
-	//"time"
-	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/pxlh007/doris/render"
 	"github.com/pxlh007/logger"
 )
 
@@ -28,16 +30,36 @@ type (
 		RouteGroup                              // 组合继承组结构和方法
 		maxParam         *int                   // 路由中的最大参数数
 		trees            trees                  // Method路由树
+		hosts            []*hostRouter          // 按Host划分的路由树
 		pool             sync.Pool              // 用于复用context上下文等对象
 		HTTPErrorHandler HTTPErrorHandler       // http错误处理函数
 		Config           map[string]interface{} // 全局用户配置器
 		Debug            bool                   // 是否处于调试模式
-		autoSlash        bool                   // 是否自动在路径的结尾添加'/'
+		routes           []*route               // 按注册顺序保存的全部路由
+		namedRoutes      map[string]*route      // 命名路由
+		lastRoutes       []*route               // 前一次注册的路由，用于Name命名
 		noRoute          HandlersChain          // 不存在路由处理链
 		noMethod         HandlersChain          // 不存在方法处理链
 		allowMethod      []string               // 允许的HTTP方法列表
 		Logger           *logger.Logger         // 全局日志记录器
 		ShowBanner       bool                   // 是否显示banner信息
+		Server           *http.Server           // 内嵌的http服务
+		ServerOptions    ServerOptions          // 内嵌http服务的超时和限制参数
+		ShutdownTimeout  time.Duration          // 优雅关闭时等待请求处理完成的最长时间
+		EnableH2C        bool                   // 是否支持明文HTTP/2(h2c)
+		AutoHEAD         bool                   // HEAD请求未注册时是否使用GET处理链
+		AutoOPTIONS      bool                   // OPTIONS请求未注册时是否自动应答
+		shutdownHooks    []func()               // 关闭时执行的钩子函数
+		shutdownOnce     sync.Once              // 保证钩子函数只执行一次
+		shutdownDone     chan struct{}          // 关闭完成（包括钩子函数执行完毕）后关闭
+		serverMu         sync.Mutex             // 保护Server和shutdownDone
+
+		RedirectTrailingSlash bool // 路由未命中时是否尝试增加或去掉结尾的'/'并重定向
+		RedirectFixedPath     bool // 路由未命中时是否尝试清理路径并忽略大小写匹配后重定向
+		StrictRouting         bool // 注册的路由冲突时是否panic，否则记录错误日志并忽略冲突的路由
+
+		HTMLRender render.HTMLRender // html模板引擎，调试模式下每次渲染前重新加载
+		FuncMap    template.FuncMap  // LoadHTMLGlob和LoadHTMLFS使用的模板函数
 		// beforeHandlers   HandlersChain       // 全局前向中间件调用链
 		// afterHandlers    HandlersChain       // 全局后向中间件调用链
 	}
@@ -51,7 +73,7 @@ type (
 	// 定义HandlerFunc数组
 	HandlersChain []HandlerFunc
 	// 集中式http错误处理器
-	HTTPErrorHandler func(error, Context)
+	HTTPErrorHandler func(error, *Context)
 	// map[string]interface{}的简短定义
 	D map[string]interface{}
 )
@@ -89,13 +111,19 @@ _________________________________Author: JonahLou__
 // 实例化框架对象函数
 func New() *Doris {
 	doris := &Doris{
-		maxParam:    new(int),
-		Logger:      logger.NewLogger(),
-		allowMethod: []string{"GET", "POST", "DELETE", "PUT", "OPTIONS", "HEAD"},
+		maxParam:        new(int),
+		trees:           make(trees),
+		Logger:          logger.NewLogger(),
+		allowMethod:     []string{"GET", "POST", "DELETE", "PUT", "OPTIONS", "HEAD"},
+		ShutdownTimeout: defaultShutdownTimeout,
+
+		RedirectTrailingSlash: true,
 	}
 	// 注册默认404和405函数
 	doris.NoMethod(defaultNoMethod)
 	doris.NoRoute(defaultNoRoute)
+	// 注册默认的集中式错误处理器
+	doris.HTTPErrorHandler = doris.DefaultHTTPErrorHandler
 	// 设置错误级别
 	//doris.Logger.SetLevel(log.ERROR)
 	doris.RouteGroup.doris = doris
@@ -115,6 +143,33 @@ func defaultNoMethod(c *Context) error {
 	return serveError(c, 405, "method not allowed!")
 }
 
+// 自动应答OPTIONS请求的函数
+func defaultOptions(c *Context) error {
+	c.Status(http.StatusNoContent)
+	return nil
+}
+
+// 默认的集中式错误处理器
+// *HTTPError（包括被%w包装的）按照其Code和Message响应，其他错误统一按500处理
+// 调试模式下会将原始错误信息返回给客户端
+func (doris *Doris) DefaultHTTPErrorHandler(err error, c *Context) {
+	var he *HTTPError
+	if !errors.As(err, &he) {
+		he = NewHTTPError(http.StatusInternalServerError)
+		if doris.Debug {
+			he.Message = err.Error()
+		}
+	}
+	if doris.Debug || he.Code >= http.StatusInternalServerError {
+		doris.Logger.Error(err.Error())
+	}
+	// 响应已经发送则无法再修改
+	if c.Response.Written() {
+		return
+	}
+	c.Json(he.Code, D{"code": he.Code, "message": he.Message})
+}
+
 // 分配一个新的上下文实例
 func (doris *Doris) allocateContext() *Context {
 	response := new(Response)
@@ -149,15 +204,74 @@ func (doris *Doris) NoMethod(handlers ...HandlerFunc) {
 	doris.noMethod = append(doris.noMethod, handlers...)
 }
 
+// 从本地文件加载html模板，模板名为文件相对于当前目录的路径
+// 模式必须是当前目录下的相对路径，其他目录的模板使用render.NewHTMLEngine(dir, patterns...)
+// 调用方式：doris.LoadHTMLGlob("templates/*.html", "./templates/users/*.html")
+func (doris *Doris) LoadHTMLGlob(patterns ...string) error {
+	cleaned := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		// 统一为fs.FS使用的路径格式，比如./templates/*.html转换为templates/*.html
+		p := filepath.ToSlash(filepath.Clean(pattern))
+		if p == "." || !fs.ValidPath(p) {
+			return fmt.Errorf("html template pattern %q must be a relative path under the working directory, use render.NewHTMLEngine for other directories", pattern)
+		}
+		cleaned[i] = p
+	}
+	engine := render.NewHTMLEngine(".", cleaned...)
+	engine.Funcs = doris.FuncMap
+	return doris.SetHTMLRender(engine)
+}
+
+// 从fs.FS加载html模板，比如embed.FS
+func (doris *Doris) LoadHTMLFS(fsys fs.FS, patterns ...string) error {
+	engine := render.NewHTMLEngineFS(fsys, patterns...)
+	engine.Funcs = doris.FuncMap
+	return doris.SetHTMLRender(engine)
+}
+
+// 设置html模板引擎并加载模板
+// 需要布局和公共模板时可以自行创建render.HTMLEngine后调用
+func (doris *Doris) SetHTMLRender(r render.HTMLRender) error {
+	if err := r.Load(); err != nil {
+		return err
+	}
+	doris.HTMLRender = r
+	return nil
+}
+
 // 添加路由方法
-func (doris *Doris) addRoute(method, path string, handlers HandlersChain) {
+// middlewares为处理链中来自组和全局中间件的数目
+// host为空时注册到默认的路由树
+// 返回记录的路由，路由冲突被忽略时返回nil
+func (doris *Doris) addRoute(host, method, path string, handlers HandlersChain, middlewares int) *route {
 	// 初始断言
 	assert1(path[0] == '/', "path must begin with '/'")
 	assert1(method != "", "HTTP method can not be empty")
 	assert1(len(handlers) > 0, "there must be at least one handler")
 	assert1(doris.validMethod(method), "method not support")
+	if host != "" {
+		doris.hostRouter(host).assertParams(path)
+	}
+	// 检查路由冲突，严格模式下直接panic，否则忽略冲突的路由
+	if err := doris.checkConflict(host, method, path); err != nil {
+		if doris.StrictRouting {
+			panic(err)
+		}
+		doris.Logger.Error(err.Error() + ", route ignored")
+		return nil
+	}
+	// 记录路由
+	r := &route{
+		host:        host,
+		method:      method,
+		path:        path,
+		handlers:    handlers,
+		middlewares: middlewares,
+	}
+	doris.routes = append(doris.routes, r)
 	// 注册路由
-	if root := doris.trees.get(method); root != nil { // 树存在
+	ts := doris.treesOf(host)
+	if root := ts.get(method); root != nil { // 树存在
 		root.debug = doris.Debug // 设置调试参数
 		root.addRoute(path, handlers)
 	} else { // 构建树
@@ -169,36 +283,13 @@ func (doris *Doris) addRoute(method, path string, handlers HandlersChain) {
 		node.prefix = "/"
 		node.debug = doris.Debug // 设置调试参数
 		node.addRoute(path, handlers)
-		if len(doris.trees) == 0 {
-			doris.trees = make(map[string]*tree)
-		}
-		doris.trees[method] = &tree{
+		ts[method] = &tree{
 			root:   node,
 			method: method,
 			doris:  doris,
 		}
 	}
-}
-
-// 运行框架程序绑定端口
-func (doris *Doris) Run(addr ...string) (err error) {
-	address := ResolveAddress(addr)
-
-	// 判断是否展示banner
-	if doris.ShowBanner {
-		// 显示banner信息
-		fmt.Printf(banner, Version, website)
-	}
-
-	// 存在多监听的时候只取第一个
-	pi := strings.Index(addr[0], ":")
-	port := addr[0][pi+1:]
-
-	// 打印引导信息
-	fmt.Printf("⇨ http server started on \033[0;32m[::]:%s\033[0m \n\n", port)
-	err = http.ListenAndServe(address, doris)
-
-	return
+	return r
 }
 
 // 实现ServerHTTP接口
@@ -207,34 +298,79 @@ func (doris *Doris) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	c.Response.reset(w)
 	c.Request = req
 	doris.handleHTTPRequest(c)
+	c.Response.finish()
+	c.reset()
 	doris.pool.Put(c)
 }
 
 // 实际处理http请求的地方
 func (doris *Doris) handleHTTPRequest(c *Context) {
 	httpMethod := c.Request.Method
-	// 判断是否允许
+	rPath := c.Request.URL.Path
+	debugPrintMessage("rPath", rPath, doris.Debug)
+	// 根据Host选择路由树
+	host, hostParams := doris.matchHost(c.Request.Host)
+	// 判断是否允许，405响应必须带Allow头（没有可用的方法时为空）
 	if !InSlice(httpMethod, doris.allowMethod) {
+		c.Response.Header().Set("Allow", strings.Join(doris.allowedMethods(host, rPath), ", "))
 		c.handlers = doris.noMethod
 		c.index = -1 // 默认设置为-1
 		c.Next()     // 执行函数处理链
 		return
 	}
-	rPath := c.Request.URL.Path
-	debugPrintMessage("rPath", rPath, doris.Debug)
 	// 查找method树
-	if tree, ok := doris.trees[httpMethod]; ok {
-		// 方法树存在
-		nodev := tree.root.find(rPath)
-		if nodev != nil && nodev.handlers != nil {
-			c.handlers = nodev.handlers
-			c.params = SliceToMap(nodev.params, nodev.pvalues)
-			c.fullPath = nodev.fullPath
-			c.index = -1 // 默认设置为-1
-			c.Next()     // 执行函数处理链
+	if nodev := doris.findRoute(host, httpMethod, rPath); nodev != nil {
+		c.handleRoute(nodev, hostParams)
+		return
+	}
+	// HEAD请求回退到GET处理链，丢弃body但保留Content-Length
+	if httpMethod == http.MethodHead && doris.AutoHEAD {
+		if nodev := doris.findRoute(host, http.MethodGet, rPath); nodev != nil {
+			hw := &headResponseWriter{ResponseWriter: c.Response.Writer}
+			c.Response.Writer = hw
+			c.handleRoute(nodev, hostParams)
+			c.Response.WriteHeaderNow()
+			hw.commit()
+			c.Response.Writer = hw.ResponseWriter
+			return
+		}
+	}
+	// 尝试增加或去掉结尾的'/'
+	// 重定向的目标开头只保留一个'/'，否则//evil.com或者/\evil.com会被浏览器当作外部地址
+	lPath := trimLeadingSlashes(rPath)
+	if doris.RedirectTrailingSlash && lPath != "/" {
+		tsr := lPath + "/"
+		if lPath[len(lPath)-1] == '/' {
+			tsr = lPath[:len(lPath)-1]
+		}
+		if doris.hasRoute(host, httpMethod, tsr) && redirectPath(c, tsr) {
 			return
 		}
 	}
+	// 尝试清理路径并忽略大小写匹配
+	if doris.RedirectFixedPath {
+		if fixed, ok := doris.findFixedPath(host, httpMethod, rPath); ok && redirectPath(c, fixed) {
+			return
+		}
+	}
+	allow := doris.allowedMethods(host, rPath)
+	// 自动应答OPTIONS请求
+	// 应答函数追加在全局中间件之后，CORS等中间件可以处理预检请求
+	if httpMethod == http.MethodOptions && doris.AutoOPTIONS && len(allow) > 0 {
+		c.SetResponseHeader("Allow", strings.Join(allow, ", "))
+		c.handlers = doris.combineHandlers(HandlersChain{defaultOptions}, false)
+		c.index = -1 // 默认设置为-1
+		c.Next()     // 执行函数处理链
+		return
+	}
+	// 其他方法下存在该路由时返回405并设置Allow头
+	if len(allow) > 0 {
+		c.SetResponseHeader("Allow", strings.Join(allow, ", "))
+		c.handlers = doris.noMethod
+		c.index = -1 // 默认设置为-1
+		c.Next()     // 执行函数处理链
+		return
+	}
 	// 方法树不存在
 	c.handlers = doris.noRoute
 	c.index = -1 // 默认设置为-1
@@ -242,6 +378,109 @@ func (doris *Doris) handleHTTPRequest(c *Context) {
 	return
 }
 
+// 在指定Host的方法树中查找路由
+func (doris *Doris) findRoute(host, method, path string) *nodeValue {
+	if tree, ok := doris.treesOf(host)[method]; ok {
+		// 方法树存在
+		if nodev := tree.root.find(path); nodev != nil && nodev.handlers != nil {
+			return nodev
+		}
+	}
+	return nil
+}
+
+// 判断请求能否命中路由（包括HEAD回退到GET的情况）
+func (doris *Doris) hasRoute(host, method, path string) bool {
+	if doris.findRoute(host, method, path) != nil {
+		return true
+	}
+	return method == http.MethodHead && doris.AutoHEAD && doris.findRoute(host, http.MethodGet, path) != nil
+}
+
+// 查找修正后的路径
+// 先清理路径中的多余'/'、'.'和'..'，再忽略大小写和已注册的路由逐个比对
+func (doris *Doris) findFixedPath(host, method, path string) (string, bool) {
+	cp := CleanPath(trimLeadingSlashes(path))
+	if cp != path && doris.hasRoute(host, method, cp) {
+		return cp, true
+	}
+	candidates := []string{cp}
+	if doris.RedirectTrailingSlash && cp != "/" {
+		if cp[len(cp)-1] == '/' {
+			candidates = append(candidates, cp[:len(cp)-1])
+		} else {
+			candidates = append(candidates, cp+"/")
+		}
+	}
+	for _, candidate := range candidates {
+		for _, r := range doris.routes {
+			if r.host != host {
+				continue
+			}
+			if r.method != method && !(method == http.MethodHead && doris.AutoHEAD && r.method == http.MethodGet) {
+				continue
+			}
+			fixed, ok := matchPathFold(r.path, candidate)
+			if ok && fixed != path && doris.hasRoute(host, method, fixed) {
+				return fixed, true
+			}
+		}
+	}
+	return "", false
+}
+
+// 重定向到修正后的路径并保留查询参数
+// GET请求使用301，其他方法使用308以保留请求方法和body
+// 目标不是本站路径时不重定向并返回false
+func redirectPath(c *Context, p string) bool {
+	if !isLocalPath(p) {
+		return false
+	}
+	code := http.StatusMovedPermanently
+	if c.Request.Method != http.MethodGet {
+		code = http.StatusPermanentRedirect
+	}
+	if q := c.Request.URL.RawQuery; q != "" {
+		p += "?" + q
+	}
+	http.Redirect(c.Response, c.Request, p, code)
+	return true
+}
+
+// 去掉路径开头连续的'/'和'\'，只保留一个'/'
+func trimLeadingSlashes(p string) string {
+	i := 0
+	for i < len(p) && (p[i] == '/' || p[i] == '\\') {
+		i++
+	}
+	return "/" + p[i:]
+}
+
+// 判断路径是否以且只以一个'/'开头
+func isLocalPath(p string) bool {
+	return len(p) > 0 && p[0] == '/' && (len(p) == 1 || p[1] != '/' && p[1] != '\\')
+}
+
+// 获取注册了指定路径的全部方法
+// 开启AutoHEAD和AutoOPTIONS时包含自动应答的方法
+func (doris *Doris) allowedMethods(host, path string) (allow []string) {
+	for _, method := range doris.allowMethod {
+		if doris.findRoute(host, method, path) != nil {
+			allow = append(allow, method)
+		}
+	}
+	if len(allow) == 0 {
+		return
+	}
+	if doris.AutoHEAD && InSlice(http.MethodGet, allow) && !InSlice(http.MethodHead, allow) {
+		allow = append(allow, http.MethodHead)
+	}
+	if doris.AutoOPTIONS && !InSlice(http.MethodOptions, allow) {
+		allow = append(allow, http.MethodOptions)
+	}
+	return
+}
+
 // 断言函数
 func assert1(guard bool, text string) {
 	if !guard { // 弹出异常并统一捕获
@@ -265,42 +504,9 @@ func serveError(c *Context, code int, defaultMessage string) error {
 	return nil
 }
 
-// 循环字典树
+// 按注册顺序打印全部路由
 func (doris *Doris) ScanTrees() {
-	var childContainer []*node
-	for _, method := range httpMethods {
-		if tree, ok := doris.trees[method]; ok {
-			root := tree.root
-			level := 0 // 层级标识
-			fmt.Println("=======" + method + "树路由开始======\n")
-			childContainer = append(childContainer, root)
-			for len(childContainer) > 0 {
-				level++
-				childContainer = debugPrintLevel(childContainer, level)
-			}
-			fmt.Println("\n=======" + method + "树路由结束======\n")
-		}
+	for _, r := range doris.Routes() {
+		fmt.Printf("%-8s %-40s --> %s (%d handlers)\n", r.Method, r.Path, r.HandlerNames[len(r.HandlerNames)-1], len(r.HandlerNames))
 	}
 }
-
-// 打印路由层信息
-func debugPrintLevel(nodes []*node, level int) (childContainer []*node) {
-	// 打印当前层获取下一层节点
-	lstr := strconv.Itoa(level)
-	if lstr == "" {
-		lstr = "0"
-	}
-	fmt.Println("当前层级level : 第【" + lstr + "】层开始\n")
-	if len(nodes) > 0 {
-		for _, child := range nodes {
-			if len(child.children) > 0 {
-				childContainer = append(childContainer, child.children...)
-			}
-			fmt.Println("\n=========================当前节点开始============================\n")
-			fmt.Printf("===节点类型：%v, 节点label：%v, 节点前缀：%v, 父节点：%v, 子节点：%v, 全路径：%v, 参数列表：%v, 节点处理链：%v===", child.nType, string(child.label), child.prefix, child.parent, child.children, child.fullPath, child.pList, child.handlers)
-			fmt.Println("\n=========================当前节点结束============================\n")
-		}
-	}
-	fmt.Println("\n当前层级level : 第【" + lstr + "】层结束\n")
-	return
-}
@@ -0,0 +1,165 @@
+package doris
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAutoOPTIONSRunsGlobalMiddleware(t *testing.T) {
+	d := New()
+	d.AutoOPTIONS = true
+	d.Use(func(c *Context) error {
+		c.SetResponseHeader("Access-Control-Allow-Origin", "*")
+		return nil
+	})
+	d.GET("/users", routeEcho("list"))
+	d.POST("/users", routeEcho("create"))
+
+	w := performRequest(d, http.MethodOptions, "/users", "Origin", "http://example.com")
+	if w.Code != http.StatusNoContent {
+		t.Errorf("got status %d, want 204", w.Code)
+	}
+	if got := w.Header().Get("Allow"); got != "GET, POST, OPTIONS" {
+		t.Errorf("got Allow %q", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("global middleware did not run for the automatic OPTIONS reply")
+	}
+
+	// 中间件自行应答时不再自动应答
+	d = New()
+	d.AutoOPTIONS = true
+	d.Use(func(c *Context) error {
+		if c.Request.Method == http.MethodOptions {
+			c.Status(http.StatusOK)
+			c.Abort()
+		}
+		return nil
+	})
+	d.GET("/users", routeEcho("list"))
+	if w := performRequest(d, http.MethodOptions, "/users"); w.Code != http.StatusOK {
+		t.Errorf("got status %d, want 200 from middleware", w.Code)
+	}
+}
+
+func TestRedirectPath(t *testing.T) {
+	tests := []struct {
+		route    string
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{"/users", "GET", "/users/", 301, "/users"},
+		{"/users/", "GET", "/users", 301, "/users/"},
+		{"/users", "GET", "/users/?page=2", 301, "/users?page=2"},
+		{"/users", "POST", "/users/", 308, "/users"},
+		{"/users", "GET", "/USERS", 301, "/users"},
+		{"/users", "GET", "/a/../users", 301, "/users"},
+		{"/users", "GET", "//users", 301, "/users"},
+		{"/users", "GET", "/\\users", 301, "/users"},
+		// 不能重定向到外部地址
+		{"/:a/:b", "GET", "//evil.com/", 404, ""},
+		{"/:a/:b/", "GET", "//evil.com", 404, ""},
+		{"/:a", "GET", "/\\evil.com/", 301, "/evil.com"},
+		{"/:a", "GET", "//\\evil.com/", 301, "/evil.com"},
+	}
+	for _, tt := range tests {
+		d := New()
+		d.RedirectFixedPath = true
+		d.Handle(tt.method, tt.route, routeEcho(tt.route))
+		req := httptest.NewRequest(tt.method, "/", nil)
+		req.URL.Path, req.URL.RawQuery, _ = strings.Cut(tt.path, "?")
+		w := httptest.NewRecorder()
+		d.ServeHTTP(w, req)
+		if w.Code != tt.code || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s %s (route %s): got %d %q, want %d %q",
+				tt.method, tt.path, tt.route, w.Code, w.Header().Get("Location"), tt.code, tt.location)
+		}
+	}
+}
+
+func TestDefaultHTTPErrorHandler(t *testing.T) {
+	tests := []struct {
+		name  string
+		debug bool
+		err   error
+		code  int
+		body  string
+	}{
+		{"http error", false, NewHTTPError(http.StatusTeapot, "teapot"), 418, `{"code":418,"message":"teapot"}`},
+		{"default message", false, ErrForbidden, 403, `{"code":403,"message":"Forbidden"}`},
+		{"wrapped", false, fmt.Errorf("load user: %w", ErrNotFound), 404, `{"code":404,"message":"Not Found"}`},
+		{"plain", false, errors.New("db down"), 500, `{"code":500,"message":"Internal Server Error"}`},
+		{"plain debug", true, errors.New("db down"), 500, `{"code":500,"message":"db down"}`},
+	}
+	for _, tt := range tests {
+		d := New()
+		d.Debug = tt.debug
+		after := false
+		d.GET("/", func(c *Context) error {
+			return tt.err
+		}, func(c *Context) error {
+			after = true
+			return nil
+		})
+		w := performRequest(d, http.MethodGet, "/")
+		if w.Code != tt.code || strings.TrimSpace(w.Body.String()) != tt.body {
+			t.Errorf("%s: got %d %q, want %d %q", tt.name, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+		if after {
+			t.Errorf("%s: handler chain continued after an error", tt.name)
+		}
+	}
+
+	// 中间件返回错误时不执行处理函数
+	d := New()
+	d.Use(func(c *Context) error {
+		return ErrUnauthorized
+	})
+	handled := false
+	d.GET("/", func(c *Context) error {
+		handled = true
+		return nil
+	})
+	if w := performRequest(d, http.MethodGet, "/"); w.Code != http.StatusUnauthorized || handled {
+		t.Errorf("got %d, handler ran=%v", w.Code, handled)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	tests := []struct {
+		autoHEAD    bool
+		autoOPTIONS bool
+		method      string
+		path        string
+		code        int
+		allow       string
+	}{
+		{false, false, "DELETE", "/users", 405, "GET, POST"},
+		{true, false, "DELETE", "/users", 405, "GET, POST, HEAD"},
+		{true, true, "DELETE", "/users", 405, "GET, POST, HEAD, OPTIONS"},
+		{false, false, "PUT", "/users/7", 405, "DELETE"},
+		// 不在allowMethod中的方法同样返回Allow头
+		{false, false, "PATCH", "/users", 405, "GET, POST"},
+		{false, false, "PATCH", "/none", 405, ""},
+		{false, false, "DELETE", "/none", 404, ""},
+	}
+	for _, tt := range tests {
+		d := New()
+		d.AutoHEAD = tt.autoHEAD
+		d.AutoOPTIONS = tt.autoOPTIONS
+		d.GET("/users", routeEcho("list"))
+		d.POST("/users", routeEcho("create"))
+		d.DELETE("/users/:id", routeEcho("delete"))
+		w := performRequest(d, tt.method, tt.path)
+		allow, ok := w.Header()["Allow"]
+		if w.Code != tt.code || strings.Join(allow, ",") != tt.allow || ok != (tt.code == 405) {
+			t.Errorf("%s %s: got %d Allow=%q, want %d %q", tt.method, tt.path, w.Code, allow, tt.code, tt.allow)
+		}
+	}
+}
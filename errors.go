@@ -3,6 +3,7 @@ package doris
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 )
 
@@ -22,3 +23,28 @@ var HTTPErrorMessages = map[int]error{
 	http.StatusRequestTimeout:        errors.New("Request timeout"),
 	http.StatusServiceUnavailable:    errors.New("Service unavailable"),
 }
+
+// 常用的http错误
+var (
+	ErrNotFound            = NewHTTPError(http.StatusNotFound)
+	ErrMethodNotAllowed    = NewHTTPError(http.StatusMethodNotAllowed)
+	ErrUnauthorized        = NewHTTPError(http.StatusUnauthorized)
+	ErrForbidden           = NewHTTPError(http.StatusForbidden)
+	ErrNotAcceptable       = NewHTTPError(http.StatusNotAcceptable)
+	ErrBadRequest          = NewHTTPError(http.StatusBadRequest)
+	ErrInternalServerError = NewHTTPError(http.StatusInternalServerError)
+)
+
+// 创建一个http错误，未传递message时使用默认的状态描述
+func NewHTTPError(code int, message ...interface{}) *HTTPError {
+	he := &HTTPError{Code: code, Message: http.StatusText(code)}
+	if len(message) > 0 {
+		he.Message = message[0]
+	}
+	return he
+}
+
+// 实现error接口
+func (he *HTTPError) Error() string {
+	return fmt.Sprintf("code=%d, message=%v", he.Code, he.Message)
+}
@@ -2,9 +2,11 @@ package doris
 
 import (
 	"net/http"
+	"net/url"
 	"path"
 	"regexp"
 	"strings"
+	"time"
 )
 
 type (
@@ -15,6 +17,9 @@ type (
 		basePath string        // 基础路径
 		doris    *Doris        // 框架对象
 		root     bool          // 是否为根节点
+		host     string        // Host模式，为空时注册到默认路由树
+
+		writeTimeout *time.Duration // 组内路由的写超时时间，为空时使用服务的设置
 	}
 	// 定义了所有路由的处理接口
 	// 包含单个的路由和组路由等
@@ -44,11 +49,20 @@ type (
 		OPTIONS(string, ...HandlerFunc) IRoutes
 		HEAD(string, ...HandlerFunc) IRoutes
 
+		// 给最后注册的路由命名
+		Name(string) IRoutes
+
 		// 注册静态文件
 		// 对应路由
 		StaticFile(string, string) IRoutes
 		Static(string, string) IRoutes
 		StaticFS(string, http.FileSystem) IRoutes
+
+		// 挂载子应用或者http.Handler
+		Mount(string, http.Handler) IRoutes
+
+		// 注册websocket路由
+		WebSocket(string, WSHandler) IRoutes
 	}
 )
 
@@ -75,6 +89,27 @@ func (group *RouteGroup) Use(middleware ...HandlerFunc) IRoutes {
 	return group.obj()
 }
 
+// 覆盖组内路由的写超时时间
+// 用于长轮询等需要长时间保持响应的接口，d<=0表示不限制
+// 只对调用之后注册的路由生效，子组和之后的调用会覆盖之前的设置
+func (group *RouteGroup) WriteTimeout(d time.Duration) IRoutes {
+	group.writeTimeout = &d
+	return group.obj()
+}
+
+// 设置写超时时间的处理函数
+func writeTimeoutHandler(d time.Duration) HandlerFunc {
+	return func(c *Context) error {
+		var deadline time.Time
+		if d > 0 {
+			deadline = time.Now().Add(d)
+		}
+		// 底层连接不支持时忽略
+		_ = http.NewResponseController(c.Response).SetWriteDeadline(deadline)
+		return nil
+	}
+}
+
 // 组方法实现分组路由
 // 同一个组的路由共用一组中间件函数
 // 分组返回组的指针
@@ -83,16 +118,37 @@ func (group *RouteGroup) Group(relativePath string, handlers ...HandlerFunc) *Ro
 		Handlers: group.combineHandlers(handlers, false),
 		basePath: group.calculateAbsolutePath(relativePath),
 		doris:    group.doris,
+		host:     group.host,
+
+		writeTimeout: group.writeTimeout,
 	}
 }
 
 // 实际的处理路由组的函数
 func (group *RouteGroup) handle(httpMethod, relativePath string, handlers ...HandlerFunc) IRoutes {
+	return group.handleMethods([]string{httpMethod}, relativePath, handlers...)
+}
+
+// 在多个HTTP方法下注册同一个路由
+// 记录本次注册的路由，Name只给这些路由命名
+func (group *RouteGroup) handleMethods(httpMethods []string, relativePath string, handlers ...HandlerFunc) IRoutes {
 	absolutePath := group.calculateAbsolutePath(relativePath)
+	middlewares := len(group.Handlers)
 	handlers = group.combineHandlers(handlers, false)
+	// 写超时放在处理链最前面，保证在其他中间件之前生效
+	if group.writeTimeout != nil {
+		handlers = append(HandlersChain{writeTimeoutHandler(*group.writeTimeout)}, handlers...)
+		middlewares++
+	}
 	// debugPrintMessage("absolutePath", absolutePath, true)
 	// debugPrintMessage("handlers", handlers, true)
-	group.doris.addRoute(httpMethod, absolutePath, handlers)
+	created := make([]*route, 0, len(httpMethods))
+	for _, method := range httpMethods {
+		if r := group.doris.addRoute(group.host, method, absolutePath, handlers, middlewares); r != nil {
+			created = append(created, r)
+		}
+	}
+	group.doris.lastRoutes = created
 	return group.obj()
 }
 
@@ -164,25 +220,23 @@ func (group *RouteGroup) TRACE(relativePath string, handlers ...HandlerFunc) IRo
 // Any方法
 // 注册HTTP的全部路由
 func (group *RouteGroup) Any(relativePath string, handlers ...HandlerFunc) IRoutes {
-	group.handle("CONNECT", relativePath, handlers...)
-	group.handle("GET", relativePath, handlers...)
-	group.handle("POST", relativePath, handlers...)
-	group.handle("PUT", relativePath, handlers...)
-	group.handle("DELETE", relativePath, handlers...)
-	group.handle("HEAD", relativePath, handlers...)
-	group.handle("OPTIONS", relativePath, handlers...)
-	group.handle("PATCH", relativePath, handlers...)
-	group.handle("TRACE", relativePath, handlers...)
-	return group.obj()
+	return group.handleMethods([]string{
+		"CONNECT", "GET", "POST", "PUT", "DELETE", "HEAD", "OPTIONS", "PATCH", "TRACE",
+	}, relativePath, handlers...)
 }
 
 // Match方法
 // 注册部分HTTP方法的路由
 // 用于定制支持的方法
 func (group *RouteGroup) Match(methods []string, relativePath string, handlers ...HandlerFunc) IRoutes {
-	for _, method := range methods {
-		group.handle(method, relativePath, handlers...)
-	}
+	return group.handleMethods(methods, relativePath, handlers...)
+}
+
+// 给前一次注册的路由命名，用于反向生成url
+// 调用方式：doris.GET("/users/:id", handler).Name("user.show")
+// 通过Any、Match或者StaticFile等一次注册的多个方法共用一个名称
+func (group *RouteGroup) Name(name string) IRoutes {
+	group.doris.nameRoute(name)
 	return group.obj()
 }
 
@@ -197,13 +251,10 @@ func (group *RouteGroup) StaticFile(relativePath, filepath string) IRoutes {
 	// 定义闭包函数调用
 	// context中的File方法
 	handler := func(c *Context) error {
-		c.File(filepath)
-		return nil
+		return c.File(filepath)
 	}
 	// 注册GET和HEAD请求处理器
-	group.GET(relativePath, handler)
-	group.HEAD(relativePath, handler)
-	return group.obj()
+	return group.handleMethods([]string{"GET", "HEAD"}, relativePath, handler)
 }
 
 // Static方法用于从现有的文件系统中提供文件服务
@@ -224,9 +275,52 @@ func (group *RouteGroup) StaticFS(relativePath string, fs http.FileSystem) IRout
 	urlPattern := path.Join(relativePath, "/*filepath")
 
 	// 注册GET和HEAD方法的handler
-	group.GET(urlPattern, handler)
-	group.HEAD(urlPattern, handler)
-	return group.obj()
+	return group.handleMethods([]string{"GET", "HEAD"}, urlPattern, handler)
+}
+
+// 将另一个*Doris实例或者任意http.Handler挂载到指定前缀下
+// 转发时去掉请求路径中的前缀，组内的中间件在转发之前执行
+// 子Doris实例使用自己的NoRoute和NoMethod处理链
+// 使用案例：router.Mount("/admin", admin)或者router.Mount("/files", http.FileServer(http.Dir("./public")))
+// 需要完整路径的handler（比如注册了pprof的http.DefaultServeMux）不能使用Mount
+// 而是使用：router.GET("/debug/pprof/*", doris.WrapHandler(http.DefaultServeMux))
+func (group *RouteGroup) Mount(relativePath string, handler http.Handler) IRoutes {
+	if strings.Contains(relativePath, ":") || strings.Contains(relativePath, "*") {
+		panic("URL parameters can not be used when mounting a handler")
+	}
+	assert1(handler != nil, "mounted handler can not be nil")
+	prefix := strings.TrimSuffix(group.calculateAbsolutePath(relativePath), "/")
+	mounted := func(c *Context) error {
+		handler.ServeHTTP(c.Response, stripPrefix(c.Request, prefix))
+		return nil
+	}
+	// 注册全部允许的方法
+	// 全匹配参数不能匹配空值，因此前缀本身和以'/'结尾的前缀需要单独注册
+	relativePath = strings.TrimSuffix(relativePath, "/")
+	if prefix != "" {
+		group.handleMethods(group.doris.allowMethod, relativePath, mounted)
+	}
+	group.handleMethods(group.doris.allowMethod, relativePath+"/", mounted)
+	return group.handleMethods(group.doris.allowMethod, relativePath+"/*", mounted)
+}
+
+// 返回去掉路径前缀后的请求副本
+func stripPrefix(req *http.Request, prefix string) *http.Request {
+	r := new(http.Request)
+	*r = *req
+	r.URL = new(url.URL)
+	*r.URL = *req.URL
+	r.URL.Path = strings.TrimPrefix(req.URL.Path, prefix)
+	if r.URL.Path == "" || r.URL.Path[0] != '/' {
+		r.URL.Path = "/" + r.URL.Path
+	}
+	if req.URL.RawPath != "" {
+		r.URL.RawPath = strings.TrimPrefix(req.URL.RawPath, prefix)
+		if r.URL.RawPath == "" || r.URL.RawPath[0] != '/' {
+			r.URL.RawPath = "/" + r.URL.RawPath
+		}
+	}
+	return r
 }
 
 // 创建静态文件处理函数
@@ -247,11 +341,11 @@ func (group *RouteGroup) createStaticHandler(relativePath string, fs http.FileSy
 			c.handlers = group.doris.noRoute
 			// 复位中间键索引值
 			c.index = -1
-			return err
+			return nil
 		}
 
 		// 调用文件服务的ServeHTTP方法
-		fileServer.ServeHTTP(c.Response.Writer, c.Request)
+		fileServer.ServeHTTP(c.Response, c.Request)
 		return nil
 	}
 }
@@ -1 +1,280 @@
 package doris
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRouteConflict(t *testing.T) {
+	tests := []struct {
+		existing string
+		path     string
+		reason   string // 为空表示不冲突
+	}{
+		{"/hello", "/hello", "duplicate route"},
+		{"/hello/:name", "/hello/:age", "param name 'age' mismatches 'name'"},
+		{"/w/:x", "/w/*y", "param '*y' overlaps ':x'"},
+		{"/w/*y", "/w/:x", "param ':x' overlaps '*y'"},
+		{"/w/*", "/w/:x", "param ':x' overlaps '*'"},
+		{"/w/:x<int>", "/w/:y<int>", "param name 'y' mismatches 'x'"},
+		{"/w/:x<int>", "/w/*y", ""},
+		{"/w/:x<int>", "/w/:x<alpha>", ""},
+		{"/w/:x", "/w/:x/edit", ""},
+		{"/w/:x/edit", "/w/*y", ""},
+		{"/w/:x", "/v/*y", ""},
+		{"/static", "/static/*file", ""},
+	}
+	for _, tt := range tests {
+		d := New()
+		d.GET(tt.existing, routeEcho("existing"))
+		err := d.checkConflict("", http.MethodGet, tt.path)
+		if tt.reason == "" {
+			if err != nil {
+				t.Errorf("%s then %s: unexpected conflict %v", tt.existing, tt.path, err)
+			}
+			continue
+		}
+		var ce *RouteConflictError
+		if !errors.As(err, &ce) {
+			t.Errorf("%s then %s: expected conflict, got %v", tt.existing, tt.path, err)
+			continue
+		}
+		if ce.Reason != tt.reason || ce.Existing != tt.existing {
+			t.Errorf("%s then %s: got %q with %s, want %q", tt.existing, tt.path, ce.Reason, ce.Existing, tt.reason)
+		}
+		// 不同方法之间不冲突
+		if err := d.checkConflict("", http.MethodPost, tt.path); err != nil {
+			t.Errorf("%s then POST %s: unexpected conflict %v", tt.existing, tt.path, err)
+		}
+	}
+}
+
+func TestRouteConflictRegistration(t *testing.T) {
+	// 非严格模式下忽略冲突的路由，保留先注册的处理函数
+	d := New()
+	d.GET("/hello/:name", routeEcho("name"))
+	d.GET("/hello/:age", routeEcho("age"))
+	d.GET("/w/:x", routeEcho("param"))
+	d.GET("/w/*y", routeEcho("all"))
+	if w := performRequest(d, http.MethodGet, "/hello/doris"); w.Body.String() != "name name=doris" {
+		t.Errorf("GET /hello/doris = %q", w.Body.String())
+	}
+	if w := performRequest(d, http.MethodGet, "/w/a/b"); w.Code != http.StatusNotFound {
+		t.Errorf("GET /w/a/b = %d, want 404", w.Code)
+	}
+	if n := len(d.Routes()); n != 2 {
+		t.Errorf("got %d routes, want 2", n)
+	}
+
+	// 严格模式下panic
+	d = New()
+	d.StrictRouting = true
+	d.GET("/hello/:name", routeEcho("name"))
+	defer func() {
+		err, _ := recover().(error)
+		if !strings.Contains(fmt.Sprint(err), "conflicts with existing route GET /hello/:name") {
+			t.Errorf("unexpected panic %v", err)
+		}
+	}()
+	d.GET("/hello/:age", routeEcho("age"))
+	t.Error("conflicting route registered in strict mode")
+}
+
+func TestNameRoute(t *testing.T) {
+	d := New()
+	d.POST("/users/:id", routeEcho("update"))
+	d.GET("/users/:id", routeEcho("show")).Name("user.show")
+	d.Match([]string{http.MethodPut, http.MethodDelete}, "/posts/:id", routeEcho("post")).Name("post.edit")
+	d.StaticFile("/favicon.ico", "./favicon.ico").Name("favicon")
+
+	names := map[string]string{}
+	for _, r := range d.Routes() {
+		names[r.Method+" "+r.Path] = r.Name
+	}
+	tests := []struct {
+		route string
+		name  string
+	}{
+		{"POST /users/:id", ""},
+		{"GET /users/:id", "user.show"},
+		{"PUT /posts/:id", "post.edit"},
+		{"DELETE /posts/:id", "post.edit"},
+		{"GET /favicon.ico", "favicon"},
+		{"HEAD /favicon.ico", "favicon"},
+	}
+	for _, tt := range tests {
+		if got := names[tt.route]; got != tt.name {
+			t.Errorf("%s: got name %q, want %q", tt.route, got, tt.name)
+		}
+	}
+	if u, err := d.URL("user.show", 7); err != nil || u != "/users/7" {
+		t.Errorf("URL(user.show) = %q, %v", u, err)
+	}
+
+	// 同一次注册的路由不能重复命名
+	defer func() {
+		if recover() == nil {
+			t.Error("naming a route twice did not panic")
+		}
+	}()
+	d.GET("/about", routeEcho("about")).Name("about").Name("about.page")
+}
+
+func TestWriteTimeoutOverride(t *testing.T) {
+	slow := func(c *Context) error {
+		time.Sleep(150 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+		return nil
+	}
+	d := New()
+	api := d.Group("/api")
+	api.WriteTimeout(50 * time.Millisecond)
+	api.GET("/short", slow)
+	api.Group("/long").WriteTimeout(0).GET("/poll", slow)
+	twice := d.Group("/twice")
+	twice.WriteTimeout(50 * time.Millisecond)
+	twice.WriteTimeout(time.Second)
+	twice.GET("/poll", slow)
+	server := httptest.NewServer(d)
+	defer server.Close()
+
+	tests := []struct {
+		path string
+		ok   bool // 是否完整收到响应
+	}{
+		{"/api/short", false},
+		{"/api/long/poll", true},
+		{"/twice/poll", true},
+	}
+	for _, tt := range tests {
+		resp, err := http.Get(server.URL + tt.path)
+		var body []byte
+		if err == nil {
+			body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		if got := err == nil && string(body) == "done"; got != tt.ok {
+			t.Errorf("%s: got body %q, err %v, want complete=%v", tt.path, body, err, tt.ok)
+		}
+	}
+}
+
+func TestMount(t *testing.T) {
+	child := New()
+	child.GET("/", routeEcho("index"))
+	child.GET("/users/:id", routeEcho("user"))
+	child.noRoute = HandlersChain{func(c *Context) error {
+		c.String(http.StatusNotFound, "child missing")
+		return nil
+	}}
+
+	d := New()
+	d.noRoute = HandlersChain{func(c *Context) error {
+		c.String(http.StatusNotFound, "parent missing")
+		return nil
+	}}
+	d.Use(func(c *Context) error {
+		c.SetResponseHeader("X-Parent", "yes")
+		return nil
+	})
+	d.Mount("/admin", child)
+	d.Group("/api").Mount("/files/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.URL.Path, r.URL.RawPath)
+	}))
+	// 需要完整路径的handler
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/cmdline", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "cmdline")
+	})
+	d.GET("/debug/pprof/*", WrapHandler(mux))
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/admin", 200, "index"},
+		{"/admin/", 200, "index"},
+		{"/admin/users/7", 200, "user id=7"},
+		{"/admin/nope", 404, "child missing"},
+		{"/api/files", 200, "/ "},
+		{"/api/files/", 200, "/ "},
+		{"/api/files/a/b.txt", 200, "/a/b.txt "},
+		{"/api/files/a%2Fb", 200, "/a/b /a%2Fb"},
+		{"/debug/pprof/cmdline", 200, "cmdline"},
+		{"/nope", 404, "parent missing"},
+	}
+	for _, tt := range tests {
+		w := performRequest(d, http.MethodGet, tt.path)
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("GET %s: got %d %q, want %d %q", tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+		if tt.path != "/nope" && w.Header().Get("X-Parent") != "yes" {
+			t.Errorf("GET %s: parent middleware did not run", tt.path)
+		}
+	}
+}
+
+func logMiddleware(c *Context) error  { return nil }
+func authMiddleware(c *Context) error { return nil }
+func listUsers(c *Context) error      { return nil }
+func showTenant(c *Context) error     { return nil }
+
+func TestRoutesInfo(t *testing.T) {
+	d := New()
+	d.Use(logMiddleware)
+	d.Group("/admin", authMiddleware).GET("/users", listUsers).Name("admin.users")
+	d.Host(":tenant.example.com").GET("/", showTenant)
+	d.DebugRoutes("/debug/routes")
+
+	const pkg = "github.com/pxlh007/doris."
+	want := []RouteInfo{
+		{
+			Method:       http.MethodGet,
+			Path:         "/admin/users",
+			HandlerNames: []string{pkg + "logMiddleware", pkg + "authMiddleware", pkg + "listUsers"},
+			Name:         "admin.users",
+			Middleware:   []string{pkg + "logMiddleware", pkg + "authMiddleware"},
+		},
+		{
+			Host:         ":tenant.example.com",
+			Method:       http.MethodGet,
+			Path:         "/",
+			HandlerNames: []string{pkg + "logMiddleware", pkg + "showTenant"},
+			Middleware:   []string{pkg + "logMiddleware"},
+		},
+	}
+	routes := d.Routes()
+	if len(routes) != len(want)+1 {
+		t.Fatalf("got %d routes, want %d", len(routes), len(want)+1)
+	}
+	for i, w := range want {
+		if fmt.Sprint(routes[i]) != fmt.Sprint(w) {
+			t.Errorf("route %d: got %+v, want %+v", i, routes[i], w)
+		}
+	}
+
+	w := performRequest(d, http.MethodGet, "/debug/routes")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), MIMEJSON) {
+		t.Fatalf("got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var got []RouteInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(routes) {
+		t.Errorf("got %+v, want %+v", got, routes)
+	}
+	// 默认路由省略host字段
+	if !strings.Contains(w.Body.String(), `{"method":"GET","path":"/admin/users","handler_names":[`) ||
+		!strings.Contains(w.Body.String(), `"host":":tenant.example.com"`) {
+		t.Errorf("unexpected json %s", w.Body.String())
+	}
+}
@@ -0,0 +1,114 @@
+package doris
+
+import (
+	"net"
+	"strings"
+)
+
+// 按Host划分的路由
+type hostRouter struct {
+	pattern string   // Host模式，比如api.example.com或者:tenant.example.com
+	labels  []string // 按'.'分割后的模式
+	static  bool     // 模式中是否不含参数
+	trees   trees    // 该Host下的Method路由树
+}
+
+// 返回只匹配指定Host的路由组
+// 模式中以':'开头的部分为参数，比如:tenant.example.com
+// 参数值可以通过Context.Param获取，路由的路径参数不能和Host参数同名
+// 请求的Host命中模式时只在该Host的路由中查找，否则使用默认路由
+func (doris *Doris) Host(pattern string, handlers ...HandlerFunc) *RouteGroup {
+	assert1(pattern != "", "host pattern can not be empty")
+	pattern = strings.ToLower(pattern)
+	if doris.hostRouter(pattern) == nil {
+		labels := strings.Split(pattern, ".")
+		static := true
+		for _, label := range labels {
+			assert1(label != "", "invalid host pattern '"+pattern+"'")
+			if label[0] == ':' {
+				assert1(len(label) > 1, "host param name can not be empty in '"+pattern+"'")
+				static = false
+			}
+		}
+		doris.hosts = append(doris.hosts, &hostRouter{
+			pattern: pattern,
+			labels:  labels,
+			static:  static,
+			trees:   make(trees),
+		})
+	}
+	return &RouteGroup{
+		Handlers: doris.combineHandlers(handlers, false),
+		basePath: doris.calculateAbsolutePath("/"),
+		doris:    doris,
+		host:     pattern,
+
+		writeTimeout: doris.writeTimeout,
+	}
+}
+
+// 根据模式查找Host路由
+func (doris *Doris) hostRouter(pattern string) *hostRouter {
+	for _, hr := range doris.hosts {
+		if hr.pattern == pattern {
+			return hr
+		}
+	}
+	return nil
+}
+
+// 检查路径参数是否和Host参数同名，同名时panic
+// 两者都存放在Context的参数中，同名时其中一个会被覆盖
+func (hr *hostRouter) assertParams(path string) {
+	for _, name := range pathParamNames(path) {
+		for _, label := range hr.labels {
+			assert1(label != ":"+name,
+				"path param '"+name+"' in '"+path+"' conflicts with host param in '"+hr.pattern+"'")
+		}
+	}
+}
+
+// 获取指定Host的路由树，host为空时返回默认路由树
+func (doris *Doris) treesOf(host string) trees {
+	if host == "" {
+		return doris.trees
+	}
+	return doris.hostRouter(host).trees
+}
+
+// 查找和请求Host匹配的模式以及从中提取的参数
+// 不含参数的模式优先，未匹配时返回空字符串
+func (doris *Doris) matchHost(reqHost string) (string, map[string]interface{}) {
+	if len(doris.hosts) == 0 {
+		return "", nil
+	}
+	// 去掉端口号
+	if h, _, err := net.SplitHostPort(reqHost); err == nil {
+		reqHost = h
+	}
+	reqHost = strings.ToLower(reqHost)
+	for _, hr := range doris.hosts {
+		if hr.static && hr.pattern == reqHost {
+			return hr.pattern, nil
+		}
+	}
+	labels := strings.Split(reqHost, ".")
+	for _, hr := range doris.hosts {
+		if hr.static || len(hr.labels) != len(labels) {
+			continue
+		}
+		params := make(map[string]interface{})
+		for i, label := range hr.labels {
+			if label[0] == ':' {
+				params[label[1:]] = labels[i]
+			} else if label != labels[i] {
+				params = nil
+				break
+			}
+		}
+		if params != nil {
+			return hr.pattern, params
+		}
+	}
+	return "", nil
+}
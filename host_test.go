@@ -0,0 +1,95 @@
+package doris
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 发送指定Host的请求
+func performHostRequest(d *Doris, host, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Host = host
+	w := httptest.NewRecorder()
+	d.ServeHTTP(w, req)
+	return w
+}
+
+func TestHostRouting(t *testing.T) {
+	d := New()
+	d.GET("/", routeEcho("default"))
+	d.GET("/users/:id", routeEcho("default user"))
+	d.Host("api.example.com").GET("/", routeEcho("api"))
+	d.Host(":tenant.example.com").GET("/", routeEcho("tenant"))
+	d.Host(":tenant.example.com").GET("/users/:id", func(c *Context) error {
+		c.String(http.StatusOK, "%v/%v", c.Param("tenant"), c.ParamString("id"))
+		return nil
+	})
+	d.Host("Static.Example.com").GET("/", routeEcho("static"))
+
+	tests := []struct {
+		host string
+		path string
+		code int
+		body string
+	}{
+		{"api.example.com", "/", 200, "api"},
+		{"api.example.com:8080", "/", 200, "api"},
+		{"API.Example.COM", "/", 200, "api"},
+		{"static.example.com", "/", 200, "static"},
+		{"acme.example.com", "/", 200, "tenant tenant=acme"},
+		{"ACME.example.com:443", "/", 200, "tenant tenant=acme"},
+		{"acme.example.com", "/users/7", 200, "acme/7"},
+		// 命中Host但路由不存在时不回退到默认路由
+		{"api.example.com", "/users/7", 404, ""},
+		// 未命中任何Host时使用默认路由
+		{"example.com", "/", 200, "default"},
+		{"a.b.example.com", "/", 200, "default"},
+		{"localhost:8080", "/users/7", 200, "default user id=7"},
+		{"[::1]:8080", "/", 200, "default"},
+	}
+	for _, tt := range tests {
+		w := performHostRequest(d, tt.host, tt.path)
+		if w.Code != tt.code || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("%s%s: got %d %q, want %d %q", tt.host, tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+}
+
+func TestHostParamConflict(t *testing.T) {
+	d := New()
+	group := d.Host(":id.example.com")
+	group.GET("/users/:name", routeEcho("ok"))
+	for _, path := range []string{"/users/:id", "/files/*id", "/items/:id<int>"} {
+		func() {
+			defer func() {
+				r := recover()
+				if r == nil || !strings.Contains(r.(string), "conflicts with host param") {
+					t.Errorf("%s: got %v, want host param conflict", path, r)
+				}
+			}()
+			group.GET(path, routeEcho("conflict"))
+		}()
+	}
+	// 默认路由不受Host参数影响
+	d.GET("/users/:id", routeEcho("default"))
+}
+
+func TestPathParamNames(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", ""},
+		{"/users/:id", "id"},
+		{"/users/:id<int>/posts/:post<^[a-z:]+$>", "id,post"},
+		{"/files/*filepath", "filepath"},
+		{"/static/*", "*"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(pathParamNames(tt.path), ","); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"doris"
 	"fmt"
+	"net/http"
 	"strconv"
 	"time"
 
@@ -19,9 +20,15 @@ func Logger() doris.HandlerFunc {
 		// 计算处理时间
 		elapsed := time.Since(begin)
 
+		// 获取状态描述
+		status := http.StatusText(c.Response.Status())
+		if msg, ok := doris.HTTPErrorMessages[c.Response.Status()]; ok {
+			status = msg.Error()
+		}
+
 		// 获取请求信息
 		logs := strconv.Itoa(c.Response.Status()) + " | " +
-			doris.HTTPErrorMessages[c.Response.Status()].Error() + " | " +
+			status + " | " +
 			fmt.Sprint(elapsed) + " | " +
 			c.Request.Host + " | " +
 			c.Request.RemoteAddr + " | " +
@@ -0,0 +1,35 @@
+package render
+
+import (
+	"net/http"
+)
+
+// 返回指定类型的原始数据
+type Data struct {
+	ContentType string // 响应类型
+	Data        []byte // 需要输出的数据
+}
+
+// 只返回状态码不返回响应体
+type NoContent struct{}
+
+// 实现渲染接口
+func (d Data) Render(w http.ResponseWriter) (err error) {
+	_, err = w.Write(d.Data)
+	return
+}
+
+// 实现类型接口
+func (d Data) WriteContentType(w http.ResponseWriter) {
+	if d.ContentType != "" {
+		writeContentType(w, []string{d.ContentType})
+	}
+}
+
+// 实现渲染接口
+func (NoContent) Render(http.ResponseWriter) error {
+	return nil
+}
+
+// 实现类型接口
+func (NoContent) WriteContentType(http.ResponseWriter) {}
@@ -2,3 +2,178 @@
 * 用于渲染html文本格式
 **/
 package render
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+)
+
+// html模板渲染器接口
+type HTMLRender interface {
+	// 加载或者重新加载模板
+	Load() error
+	// 返回指定模板的渲染器
+	Instance(name string, data interface{}) (IRender, error)
+}
+
+// 返回html格式
+type HTML struct {
+	Template *template.Template // 模板集
+	Name     string             // 需要执行的模板名
+	Data     interface{}        // 需要渲染的数据
+}
+
+// 基于html/template的模板引擎
+// 模板名为文件相对于文件系统根目录的路径，比如users/show.html
+// 每个页面单独组成一个模板集，包含布局、公共模板以及页面本身
+// 因此不同页面中可以定义同名的block
+type HTMLEngine struct {
+	FS       fs.FS            // 模板所在的文件系统
+	Patterns []string         // 页面模板的glob模式
+	Layout   string           // 布局模板名，设置后页面通过布局模板渲染
+	Partials []string         // 所有页面共用的公共模板的glob模式
+	Funcs    template.FuncMap // 自定义模板函数
+	Delims   []string         // 自定义模板分隔符，比如[]string{"[[", "]]"}
+
+	mu        sync.RWMutex
+	base      *template.Template            // 布局和公共模板
+	templates map[string]*template.Template // 页面模板集
+}
+
+// 定义各种content_type类型
+var (
+	htmlContentType = []string{"text/html; charset=utf-8"}
+)
+
+// 使用本地目录创建模板引擎，patterns为相对于dir的glob模式
+// 调用方式：render.NewHTMLEngine("templates", "*.html", "users/*.html")
+func NewHTMLEngine(dir string, patterns ...string) *HTMLEngine {
+	return NewHTMLEngineFS(os.DirFS(dir), patterns...)
+}
+
+// 使用fs.FS创建模板引擎，比如embed.FS
+func NewHTMLEngineFS(fsys fs.FS, patterns ...string) *HTMLEngine {
+	return &HTMLEngine{FS: fsys, Patterns: patterns}
+}
+
+// 解析全部模板
+func (e *HTMLEngine) Load() error {
+	base := template.New("")
+	if len(e.Delims) == 2 {
+		base.Delims(e.Delims[0], e.Delims[1])
+	}
+	base.Funcs(e.Funcs)
+
+	// 解析布局和公共模板
+	shared, err := e.glob(e.Partials)
+	if err != nil {
+		return err
+	}
+	if e.Layout != "" {
+		shared[e.Layout] = true
+	}
+	for _, name := range sortedNames(shared) {
+		if err = e.parse(base, name); err != nil {
+			return err
+		}
+	}
+
+	// 每个页面基于公共模板单独解析
+	pages, err := e.glob(e.Patterns)
+	if err != nil {
+		return err
+	}
+	templates := make(map[string]*template.Template, len(pages))
+	for name := range pages {
+		if shared[name] {
+			continue
+		}
+		t, err := base.Clone()
+		if err != nil {
+			return err
+		}
+		if err = e.parse(t, name); err != nil {
+			return err
+		}
+		templates[name] = t
+	}
+
+	e.mu.Lock()
+	e.base = base
+	e.templates = templates
+	e.mu.Unlock()
+	return nil
+}
+
+// 返回指定模板的渲染器
+// 设置了布局时页面通过布局模板渲染，公共模板可以直接渲染
+func (e *HTMLEngine) Instance(name string, data interface{}) (IRender, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if t, ok := e.templates[name]; ok {
+		if e.Layout != "" {
+			return HTML{Template: t, Name: e.Layout, Data: data}, nil
+		}
+		return HTML{Template: t, Name: name, Data: data}, nil
+	}
+	if e.base != nil && e.base.Lookup(name) != nil {
+		return HTML{Template: e.base, Name: name, Data: data}, nil
+	}
+	return nil, fmt.Errorf("html template %q is undefined", name)
+}
+
+// 展开glob模式
+func (e *HTMLEngine) glob(patterns []string) (map[string]bool, error) {
+	names := make(map[string]bool)
+	for _, pattern := range patterns {
+		if !fs.ValidPath(pattern) {
+			return nil, fmt.Errorf("html template pattern %q is not a valid fs path (slash-separated, relative, without '.' or '..' elements)", pattern)
+		}
+		matches, err := fs.Glob(e.FS, pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("html template pattern %q matches no files", pattern)
+		}
+		for _, name := range matches {
+			names[name] = true
+		}
+	}
+	return names, nil
+}
+
+// 将文件解析到模板集中，模板名为文件路径
+func (e *HTMLEngine) parse(t *template.Template, name string) error {
+	content, err := fs.ReadFile(e.FS, name)
+	if err != nil {
+		return err
+	}
+	_, err = t.New(name).Parse(string(content))
+	return err
+}
+
+// 按名称排序保证解析顺序稳定
+func sortedNames(names map[string]bool) []string {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// 实现渲染接口
+func (h HTML) Render(w http.ResponseWriter) error {
+	return h.Template.ExecuteTemplate(w, h.Name, h.Data)
+}
+
+// 实现类型接口
+func (h HTML) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, htmlContentType)
+}
@@ -0,0 +1,91 @@
+package render
+
+import (
+	"html/template"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// 渲染模板并返回输出
+func renderHTML(t *testing.T, e *HTMLEngine, name string, data interface{}) string {
+	t.Helper()
+	r, err := e.Instance(name, data)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	w := httptest.NewRecorder()
+	if err := r.Render(w); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return w.Body.String()
+}
+
+func TestHTMLEngineLayout(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layout.html":          {Data: []byte(`<main>{{block "content" .}}default{{end}}</main>{{template "partials/footer.html" .}}`)},
+		"partials/footer.html": {Data: []byte(`<footer>{{upper .}}</footer>`)},
+		"pages/a.html":         {Data: []byte(`{{define "content"}}A {{.}}{{end}}`)},
+		"pages/b.html":         {Data: []byte(`{{define "content"}}B {{upper .}}{{end}}`)},
+		"pages/c.html":         {Data: []byte(`no blocks`)},
+	}
+	e := NewHTMLEngineFS(fsys, "pages/*.html")
+	e.Layout = "layout.html"
+	e.Partials = []string{"partials/*.html"}
+	e.Funcs = template.FuncMap{"upper": strings.ToUpper}
+	if err := e.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		// 不同页面中同名的block互不影响
+		{"pages/a.html", "<main>A x</main><footer>X</footer>"},
+		{"pages/b.html", "<main>B X</main><footer>X</footer>"},
+		{"pages/c.html", "<main>default</main><footer>X</footer>"},
+		// 公共模板可以直接渲染
+		{"partials/footer.html", "<footer>X</footer>"},
+	}
+	for _, tt := range tests {
+		if got := renderHTML(t, e, tt.name, "x"); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if _, err := e.Instance("pages/missing.html", nil); err == nil {
+		t.Error("expected an error for an undefined template")
+	}
+}
+
+func TestHTMLEngineDelims(t *testing.T) {
+	fsys := fstest.MapFS{"index.html": {Data: []byte(`[[.]] {{raw}}`)}}
+	e := NewHTMLEngineFS(fsys, "*.html")
+	e.Delims = []string{"[[", "]]"}
+	if err := e.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := renderHTML(t, e, "index.html", "<b>"); got != "&lt;b&gt; {{raw}}" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestHTMLEnginePatternErrors(t *testing.T) {
+	fsys := fstest.MapFS{"index.html": {Data: []byte(`index`)}}
+	tests := []struct {
+		pattern string
+		err     string
+	}{
+		{"./index.html", "not a valid fs path"},
+		{"/index.html", "not a valid fs path"},
+		{"../*.html", "not a valid fs path"},
+		{"*.tmpl", "matches no files"},
+	}
+	for _, tt := range tests {
+		err := NewHTMLEngineFS(fsys, tt.pattern).Load()
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want %q", tt.pattern, err, tt.err)
+		}
+	}
+}
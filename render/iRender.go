@@ -12,6 +12,13 @@ type IRender interface {
 	WriteContentType(http.ResponseWriter)
 }
 
+// 流式渲染器接口
+// Streaming返回true时直接写入响应而不先写入缓冲区，比如SSE和Reader
+type StreamRender interface {
+	IRender
+	Streaming() bool
+}
+
 // 声明接口的实现对象
 var (
 	_ IRender = Json{}
@@ -19,10 +26,21 @@ var (
 	_ IRender = IndentedJson{}
 	_ IRender = PureJson{}
 	_ IRender = AsciiJson{}
-	// _ IRender = xml{}
+	_ IRender = HTML{}
+	_ IRender = Xml{}
 	// _ IRender = ProtoBuf{}
 	// _ IRender = Text{}
-	// _ IRender = Yaml{}
+	_ IRender = Yaml{}
+	_ IRender = Data{}
+	_ IRender = NoContent{}
+	_ IRender = Reader{}
+	_ IRender = Redirect{}
+	_ IRender = SSE{}
+
+	_ StreamRender = SSE{}
+	_ StreamRender = Reader{}
+
+	_ HTMLRender = &HTMLEngine{}
 )
 
 // 更新当前请求的content_type头信息
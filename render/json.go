@@ -44,10 +44,7 @@ var (
 
 // 实现渲染接口
 func (j Json) Render(w http.ResponseWriter) error {
-	if err := j.WriteJson(w); err != nil {
-		panic(err)
-	}
-	return nil
+	return j.WriteJson(w)
 }
 
 // 写类型接口
@@ -0,0 +1,41 @@
+package render
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// 从io.Reader中流式输出数据，不在内存中缓冲整个响应体
+type Reader struct {
+	ContentType   string            // 响应类型
+	ContentLength int64             // 数据长度，小于0时表示未知长度
+	Reader        io.Reader         // 数据来源
+	Headers       map[string]string // 额外的响应头，比如Content-Disposition
+}
+
+// 实现渲染接口
+func (r Reader) Render(w http.ResponseWriter) (err error) {
+	_, err = io.Copy(w, r.Reader)
+	return
+}
+
+// 大文件直接从reader复制到响应，不使用缓冲
+func (r Reader) Streaming() bool {
+	return true
+}
+
+// 实现类型接口
+// Content-Length和额外的响应头需要在写入状态码之前一起设置
+func (r Reader) WriteContentType(w http.ResponseWriter) {
+	if r.ContentType != "" {
+		writeContentType(w, []string{r.ContentType})
+	}
+	header := w.Header()
+	if r.ContentLength >= 0 {
+		header.Set("Content-Length", strconv.FormatInt(r.ContentLength, 10))
+	}
+	for key, value := range r.Headers {
+		header.Set(key, value)
+	}
+}
@@ -0,0 +1,34 @@
+package render
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// 重定向到指定地址
+type Redirect struct {
+	Code     int           // 重定向状态码，3xx或者201
+	Request  *http.Request // 当前请求，用于解析相对地址
+	Location string        // 重定向地址
+}
+
+// 实现渲染接口
+// 状态码和Location头由http.Redirect写入
+func (r Redirect) Render(w http.ResponseWriter) error {
+	if !IsRedirectCode(r.Code) {
+		return fmt.Errorf("cannot redirect with status code %d", r.Code)
+	}
+	http.Redirect(w, r.Request, r.Location, r.Code)
+	return nil
+}
+
+// 实现类型接口
+// 重定向的响应体由http.Redirect决定，这里不设置类型
+func (r Redirect) WriteContentType(http.ResponseWriter) {}
+
+// 判断是否是可以用于重定向的状态码
+// 201 Created同样通过Location头返回新资源的地址
+func IsRedirectCode(code int) bool {
+	return (code >= http.StatusMultipleChoices && code <= http.StatusPermanentRedirect) ||
+		code == http.StatusCreated
+}
@@ -0,0 +1,104 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pxlh007/doris/internal/json"
+)
+
+// 服务端推送事件（Server-Sent Events）
+// 每次渲染输出一个事件并立即刷新到客户端
+type SSE struct {
+	Id    string      // 事件id，客户端重连时通过Last-Event-ID头带回
+	Event string      // 事件名，为空时客户端按message事件处理
+	Retry uint        // 客户端重连的等待时间（毫秒），为0时不设置
+	Data  interface{} // 事件数据，string和[]byte原样输出，其他类型编码为json
+}
+
+// 定义各种content_type类型
+var (
+	sseContentType = []string{"text/event-stream"}
+)
+
+// 换行符会破坏事件结构，id和event字段中直接去掉
+var fieldReplacer = strings.NewReplacer("\n", "", "\r", "")
+
+// 数据中的各种换行符统一为\n后按行拆分
+var lineReplacer = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// 实现渲染接口
+func (s SSE) Render(w http.ResponseWriter) error {
+	var buf bytes.Buffer
+	if err := s.encode(&buf); err != nil {
+		return err
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// 实现类型接口
+// 同时关闭缓存，并通知nginx等代理不要缓冲响应
+func (s SSE) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, sseContentType)
+	header := w.Header()
+	if header.Get("Cache-Control") == "" {
+		header.Set("Cache-Control", "no-cache")
+	}
+	header.Set("X-Accel-Buffering", "no")
+}
+
+// 每个事件都需要立即发送给客户端，不使用缓冲
+func (s SSE) Streaming() bool {
+	return true
+}
+
+// 按照事件流格式编码
+func (s SSE) encode(buf *bytes.Buffer) error {
+	if s.Id != "" {
+		buf.WriteString("id: " + fieldReplacer.Replace(s.Id) + "\n")
+	}
+	if s.Event != "" {
+		buf.WriteString("event: " + fieldReplacer.Replace(s.Event) + "\n")
+	}
+	if s.Retry > 0 {
+		fmt.Fprintf(buf, "retry: %d\n", s.Retry)
+	}
+	data, err := sseData(s.Data)
+	if err != nil {
+		return err
+	}
+	// 多行数据需要拆分为多个data字段
+	for _, line := range strings.Split(lineReplacer.Replace(data), "\n") {
+		buf.WriteString("data: " + line + "\n")
+	}
+	// 空行表示事件结束
+	buf.WriteString("\n")
+	return nil
+}
+
+// 将事件数据转换为字符串
+func sseData(data interface{}) (string, error) {
+	switch v := data.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
@@ -19,10 +19,7 @@ var (
 
 // 实现渲染接口
 func (s String) Render(w http.ResponseWriter) error {
-	if err := s.WriteString(w); err != nil {
-		panic(err)
-	}
-	return nil
+	return s.WriteString(w)
 }
 
 // 实现类型接口
@@ -1,4 +1,39 @@
 package render
 
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 返回yaml格式
 type Yaml struct {
+	Data interface{} // 需要渲染的数据
+}
+
+// 定义各种content_type类型
+var (
+	yamlContentType = []string{"application/x-yaml; charset=utf-8"}
+)
+
+// 实现渲染接口
+func (y Yaml) Render(w http.ResponseWriter) (err error) {
+	// yaml.Marshal遇到函数、通道等不支持的类型时直接panic，转换为错误返回
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("yaml: %v", r)
+		}
+	}()
+	bytes, err := yaml.Marshal(y.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(bytes)
+	return err
+}
+
+// 实现类型接口
+func (y Yaml) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, yamlContentType)
 }
@@ -9,6 +9,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"strconv"
 )
 
 const (
@@ -41,12 +42,20 @@ type ResponseWriter interface {
 
 	// get the http.Pusher for server push
 	Pusher() http.Pusher
+
+	// 注册提交响应头之前执行的函数
+	Before(func())
+
+	// 注册响应体写完之后执行的函数
+	After(func())
 }
 
 type Response struct {
-	size   int
-	status int
-	Writer http.ResponseWriter
+	size        int
+	status      int
+	Writer      http.ResponseWriter
+	beforeFuncs []func() // 提交响应头之前执行的函数
+	afterFuncs  []func() // 响应体写完之后执行的函数
 }
 
 // Response结构实现了上述接口
@@ -56,26 +65,57 @@ func (w *Response) reset(writer http.ResponseWriter) {
 	w.Writer = writer
 	w.size = noWritten
 	w.status = defaultStatus
+	w.beforeFuncs = nil
+	w.afterFuncs = nil
+}
+
+// 注册在提交响应头之前执行的函数，按注册顺序执行
+// 可以在其中修改响应头和状态码，比如设置cookie、计时头和ETag
+func (w *Response) Before(fn func()) {
+	w.beforeFuncs = append(w.beforeFuncs, fn)
+}
+
+// 注册在响应体写完之后执行的函数，按注册顺序执行
+// 在处理链结束之后执行，此时响应头已经提交
+func (w *Response) After(fn func()) {
+	w.afterFuncs = append(w.afterFuncs, fn)
 }
 
 // 将code值写入w中后面调用WriteHeaderNow再发送
+// 响应头已经提交之后不能再修改状态码
 func (w *Response) WriteHeader(code int) {
-	if code > 0 && w.status != code {
-		if w.Written() {
-			// debugPrint("[WARNING] Headers were already written. Wanted to override status code %d with %d", w.status, code)
-		}
+	if code > 0 && w.status != code && !w.Written() {
 		w.status = code
 	}
 }
 
-// 本接口暂时用不上
+// 执行Before函数后立即提交响应头
 func (w *Response) WriteHeaderNow() {
+	if w.Written() {
+		return
+	}
+	// 先取出再执行，防止在Before函数中写入时重复执行
+	hooks := w.beforeFuncs
+	w.beforeFuncs = nil
+	for _, fn := range hooks {
+		fn()
+	}
 	if !w.Written() {
 		w.size = 0
 		w.Writer.WriteHeader(w.status)
 	}
 }
 
+// 处理链结束之后提交尚未写入的响应头，然后执行After函数
+func (w *Response) finish() {
+	w.WriteHeaderNow()
+	hooks := w.afterFuncs
+	w.afterFuncs = nil
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
 func (w *Response) Write(data []byte) (n int, err error) {
 	w.WriteHeaderNow()
 	n, err = w.Writer.Write(data)
@@ -103,22 +143,33 @@ func (w *Response) Written() bool {
 }
 
 // Hijack implements the http.Hijacker interface.
+// 底层的ResponseWriter不支持时返回http.ErrNotSupported
 func (w *Response) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.Writer.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
 	if w.size < 0 {
 		w.size = 0
 	}
-	return w.Writer.(http.Hijacker).Hijack()
+	return hijacker.Hijack()
 }
 
 // CloseNotify implements the http.CloseNotify interface.
+// 底层的ResponseWriter不支持时返回nil，从中读取会一直阻塞
 func (w *Response) CloseNotify() <-chan bool {
-	return w.Writer.(http.CloseNotifier).CloseNotify()
+	if notifier, ok := w.Writer.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
+	return nil
 }
 
 // Flush implements the http.Flush interface.
 func (w *Response) Flush() {
 	w.WriteHeaderNow()
-	w.Writer.(http.Flusher).Flush()
+	if flusher, ok := w.Writer.(http.Flusher); ok {
+		flusher.Flush()
+	}
 }
 
 func (w *Response) Pusher() (pusher http.Pusher) {
@@ -128,7 +179,76 @@ func (w *Response) Pusher() (pusher http.Pusher) {
 	return nil
 }
 
+// 返回底层ResponseWriter的响应头
 func (w *Response) Header() http.Header {
-	debugPrintMessage("reponse: Header方法", "__print__", true)
+	return w.Writer.Header()
+}
+
+// 返回底层的ResponseWriter，用于http.ResponseController
+func (w *Response) Unwrap() http.ResponseWriter {
+	return w.Writer
+}
+
+// HEAD请求使用GET处理链时的响应包装
+// 丢弃写入的body并统计长度，处理链结束后再提交响应头
+type headResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+// 只记录第一次设置的状态码
+func (w *headResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+// 丢弃body只统计长度
+func (w *headResponseWriter) Write(data []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	w.size += len(data)
+	return len(data), nil
+}
+
+// body被丢弃因此无需刷新
+func (w *headResponseWriter) Flush() {}
+
+// 转发给原始的ResponseWriter，不支持时返回nil
+func (w *headResponseWriter) CloseNotify() <-chan bool {
+	if notifier, ok := w.ResponseWriter.(http.CloseNotifier); ok {
+		return notifier.CloseNotify()
+	}
 	return nil
 }
+
+// 转发给原始的ResponseWriter，不支持时返回http.ErrNotSupported
+func (w *headResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// 转发给原始的ResponseWriter，不支持时返回http.ErrNotSupported
+func (w *headResponseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := w.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// 返回原始的ResponseWriter，用于http.ResponseController
+func (w *headResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// 设置Content-Length并提交响应头
+func (w *headResponseWriter) commit() {
+	w.WriteHeader(http.StatusOK)
+	header := w.Header()
+	if header.Get("Content-Length") == "" && w.size > 0 {
+		header.Set("Content-Length", strconv.Itoa(w.size))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
@@ -0,0 +1,115 @@
+package doris
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseHooks(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &Response{}
+	w.reset(rec)
+	var calls []string
+	w.Before(func() {
+		calls = append(calls, "before1")
+	})
+	w.Before(func() {
+		calls = append(calls, "before2")
+		// Before中仍然可以修改响应头和状态码
+		w.Header().Set("X-Hook", "yes")
+		w.WriteHeader(http.StatusCreated)
+	})
+	w.After(func() {
+		calls = append(calls, "after1")
+	})
+	w.After(func() {
+		calls = append(calls, "after2")
+	})
+	w.WriteString("hello")
+	w.WriteString(" world")
+	if got := strings.Join(calls, ","); got != "before1,before2" {
+		t.Errorf("after write: got %q", got)
+	}
+	w.finish()
+	if got := strings.Join(calls, ","); got != "before1,before2,after1,after2" {
+		t.Errorf("after finish: got %q", got)
+	}
+	if rec.Code != http.StatusCreated || rec.Header().Get("X-Hook") != "yes" || rec.Body.String() != "hello world" {
+		t.Errorf("got %d %q %q", rec.Code, rec.Header().Get("X-Hook"), rec.Body.String())
+	}
+	if w.Size() != len("hello world") {
+		t.Errorf("got size %d", w.Size())
+	}
+}
+
+func TestResponseAsResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &Response{}
+	w.reset(rec)
+	var hw http.ResponseWriter = w
+	hw.Header().Set("X-Custom", "1")
+	http.Error(hw, "gone", http.StatusGone)
+	w.finish()
+	if rec.Code != http.StatusGone || rec.Body.String() != "gone\n" {
+		t.Errorf("got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Custom") != "1" || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("got header %v", rec.Header())
+	}
+}
+
+func TestResponseStatusLockedAfterCommit(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &Response{}
+	w.reset(rec)
+	w.WriteHeader(http.StatusAccepted)
+	w.WriteHeader(http.StatusCreated) // 提交之前可以修改
+	w.WriteHeaderNow()
+	w.WriteHeader(http.StatusInternalServerError)
+	w.WriteString("ok")
+	w.finish()
+	if w.Status() != http.StatusCreated || rec.Code != http.StatusCreated {
+		t.Errorf("got status %d, recorded %d, want 201", w.Status(), rec.Code)
+	}
+}
+
+func TestResponseAutoHEADOptionalInterfaces(t *testing.T) {
+	d := New()
+	d.AutoHEAD = true
+	d.GET("/", func(c *Context) error {
+		c.SetResponseHeader("X-Notify", boolString(c.Response.CloseNotify() != nil))
+		if c.Request.Host == "example.com" { // 只在recorder上尝试，真实连接会被接管
+			_, _, err := c.Response.Hijack()
+			c.SetResponseHeader("X-Hijack", boolString(err == http.ErrNotSupported))
+		}
+		c.String(http.StatusOK, "body")
+		return nil
+	})
+
+	// httptest.ResponseRecorder不支持CloseNotifier和Hijacker
+	w := performRequest(d, http.MethodHead, "/")
+	if w.Code != http.StatusOK || w.Header().Get("X-Notify") != "false" || w.Header().Get("X-Hijack") != "true" {
+		t.Errorf("recorder: got %d %v", w.Code, w.Header())
+	}
+
+	// 真实连接上的HEAD请求可以使用原始ResponseWriter的CloseNotify
+	srv := httptest.NewServer(d)
+	defer srv.Close()
+	res, err := http.Head(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("X-Notify") != "true" || res.ContentLength != 4 {
+		t.Errorf("server: got %d %v length %d", res.StatusCode, res.Header, res.ContentLength)
+	}
+}
+
+func boolString(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
@@ -0,0 +1,247 @@
+package doris
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// 注册的路由记录
+type route struct {
+	host        string        // Host模式，为空时表示默认路由
+	method      string        // HTTP方法
+	path        string        // 路由全路径
+	name        string        // 路由名称
+	handlers    HandlersChain // 完整的处理链
+	middlewares int           // 处理链中中间件的数目
+}
+
+// 路径解析后的片段
+type pathToken struct {
+	kind       nodeType // 片段类型：静态，参数，全匹配
+	text       string   // 静态片段的内容或者参数名
+	constraint string   // 参数约束
+}
+
+// 路由冲突错误
+type RouteConflictError struct {
+	Method   string // HTTP方法
+	Path     string // 新注册的路由
+	Existing string // 已经存在的路由
+	Reason   string // 冲突原因
+}
+
+// 实现error接口
+func (e *RouteConflictError) Error() string {
+	return fmt.Sprintf("route conflict: %s %s conflicts with existing route %s %s (%s)",
+		e.Method, e.Path, e.Method, e.Existing, e.Reason)
+}
+
+// 对外暴露的路由信息
+type RouteInfo struct {
+	Host         string   `json:"host,omitempty"` // Host模式
+	Method       string   `json:"method"`         // HTTP方法
+	Path         string   `json:"path"`           // 路由全路径
+	HandlerNames []string `json:"handler_names"`  // 完整处理链的函数名
+	Name         string   `json:"name"`           // 路由名称
+	Middleware   []string `json:"middleware"`     // 处理链中中间件的函数名
+}
+
+// 按注册顺序返回全部路由的信息
+func (doris *Doris) Routes() []RouteInfo {
+	infos := make([]RouteInfo, 0, len(doris.routes))
+	for _, r := range doris.routes {
+		names := make([]string, len(r.handlers))
+		for i, h := range r.handlers {
+			names[i] = nameOfFunction(h)
+		}
+		infos = append(infos, RouteInfo{
+			Host:         r.host,
+			Method:       r.method,
+			Path:         r.path,
+			HandlerNames: names,
+			Name:         r.name,
+			Middleware:   names[:r.middlewares],
+		})
+	}
+	return infos
+}
+
+// 注册以json格式输出路由表的调试接口
+// 调用方式：doris.DebugRoutes("/debug/routes")
+func (doris *Doris) DebugRoutes(relativePath string) IRoutes {
+	return doris.GET(relativePath, func(c *Context) error {
+		c.Json(http.StatusOK, doris.Routes())
+		return nil
+	})
+}
+
+// 将路径解析为静态、参数和全匹配片段
+func tokenizePath(p string) (tokens []pathToken) {
+	for p != "" {
+		i := wildcardIndex(p)
+		if i == -1 {
+			tokens = append(tokens, pathToken{kind: skind, text: p})
+			break
+		}
+		if i > 0 {
+			tokens = append(tokens, pathToken{kind: skind, text: p[:i]})
+			p = p[i:]
+		}
+		if p[0] == '*' {
+			name, end := parseWildcard(p)
+			tokens = append(tokens, pathToken{kind: akind, text: name})
+			p = p[end:]
+			continue
+		}
+		name, constraint, end := parseParam(p)
+		tokens = append(tokens, pathToken{kind: pkind, text: name, constraint: constraint})
+		p = p[end:]
+	}
+	return
+}
+
+// 参数片段在路径中的写法
+func tokenString(t pathToken) string {
+	if t.kind == akind {
+		if t.text == "*" {
+			return "*"
+		}
+		return "*" + t.text
+	}
+	return ":" + t.text
+}
+
+// 检查新路由是否和已注册的路由冲突
+// 冲突包括：重复注册的路由，匹配范围完全相同但参数名不同的路由，
+// 以及同一位置上分别为无约束参数和全匹配参数的路由
+func (doris *Doris) checkConflict(host, method, path string) error {
+	tokens := tokenizePath(path)
+	for _, r := range doris.routes {
+		if r.host != host || r.method != method {
+			continue
+		}
+		if r.path == path {
+			return &RouteConflictError{Method: method, Path: path, Existing: r.path, Reason: "duplicate route"}
+		}
+		existing := tokenizePath(r.path)
+		if len(existing) != len(tokens) {
+			continue
+		}
+		same, mismatch := true, ""
+		for i, t := range tokens {
+			e := existing[i]
+			if t.kind != e.kind {
+				// 同一位置上无约束的参数和全匹配参数匹配同样的值
+				if t.kind == skind || e.kind == skind || t.constraint != "" || e.constraint != "" {
+					same = false
+					break
+				}
+				if mismatch == "" {
+					mismatch = fmt.Sprintf("param '%s' overlaps '%s'", tokenString(t), tokenString(e))
+				}
+				continue
+			}
+			if (t.kind == skind && t.text != e.text) || t.constraint != e.constraint {
+				same = false
+				break
+			}
+			if t.kind != skind && t.text != e.text && mismatch == "" {
+				mismatch = fmt.Sprintf("param name '%s' mismatches '%s'", t.text, e.text)
+			}
+		}
+		if same {
+			if mismatch == "" {
+				mismatch = "duplicate route"
+			}
+			return &RouteConflictError{Method: method, Path: path, Existing: r.path, Reason: mismatch}
+		}
+	}
+	return nil
+}
+
+// 给前一次注册的路由命名
+// 通过Any、Match等一次注册的多个方法的路由使用同一个名称
+func (doris *Doris) nameRoute(name string) {
+	assert1(name != "", "route name can not be empty")
+	assert1(len(doris.lastRoutes) > 0, "there is no route to name '"+name+"'")
+	if r, ok := doris.namedRoutes[name]; ok {
+		panic("route name '" + name + "' is already used by '" + r.method + " " + r.path + "'")
+	}
+	for _, r := range doris.lastRoutes {
+		assert1(r.name == "", "route '"+r.method+" "+r.path+"' is already named '"+r.name+"'")
+		r.name = name
+	}
+	if doris.namedRoutes == nil {
+		doris.namedRoutes = make(map[string]*route)
+	}
+	doris.namedRoutes[name] = doris.lastRoutes[0]
+}
+
+// 根据路由名称和参数生成url
+// 参数按照在路径中出现的顺序传递，也可以传递一个D按参数名传递
+// 参数缺失、多余或者不满足约束时返回错误
+func (doris *Doris) URL(name string, params ...interface{}) (string, error) {
+	r, ok := doris.namedRoutes[name]
+	if !ok {
+		return "", fmt.Errorf("route '%s' not found", name)
+	}
+	var named map[string]interface{}
+	if len(params) == 1 {
+		switch m := params[0].(type) {
+		case D:
+			named = m
+		case map[string]interface{}:
+			named = m
+		}
+	}
+
+	var (
+		b     strings.Builder
+		index int // 已使用的参数数
+	)
+	// 获取下一个参数值
+	next := func(key string) (string, error) {
+		if named != nil {
+			v, ok := named[key]
+			if !ok {
+				return "", fmt.Errorf("route '%s' (%s) missing param '%s'", name, r.path, key)
+			}
+			index++
+			return fmt.Sprint(v), nil
+		}
+		if index >= len(params) {
+			return "", fmt.Errorf("route '%s' (%s) missing param '%s'", name, r.path, key)
+		}
+		index++
+		return fmt.Sprint(params[index-1]), nil
+	}
+	for _, t := range tokenizePath(r.path) {
+		if t.kind == skind {
+			b.WriteString(t.text)
+			continue
+		}
+		v, err := next(t.text)
+		if err != nil {
+			return "", err
+		}
+		if t.kind == akind {
+			// 全匹配参数保留'/'，逐段转义
+			segs := strings.Split(v, "/")
+			for k := range segs {
+				segs[k] = url.PathEscape(segs[k])
+			}
+			b.WriteString(strings.Join(segs, "/"))
+			continue
+		}
+		if re := compileConstraint(t.constraint); re != nil && !re.MatchString(v) {
+			return "", fmt.Errorf("route '%s' (%s) param '%s' value '%s' does not match '%s'", name, r.path, t.text, v, t.constraint)
+		}
+		b.WriteString(url.PathEscape(v))
+	}
+	if (named == nil && index < len(params)) || (named != nil && index < len(named)) {
+		return "", fmt.Errorf("route '%s' (%s) got too many params", name, r.path)
+	}
+	return b.String(), nil
+}
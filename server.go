@@ -0,0 +1,260 @@
+package doris
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/pxlh007/doris/config"
+)
+
+// 默认的优雅关闭等待时间
+const defaultShutdownTimeout = 10 * time.Second
+
+// 内嵌http服务的超时和限制参数
+// 零值表示使用net/http的默认行为（不限制）
+type ServerOptions struct {
+	ReadTimeout       time.Duration // 读取整个请求（包括body）的超时时间
+	ReadHeaderTimeout time.Duration // 读取请求头的超时时间，用于防范slowloris攻击
+	WriteTimeout      time.Duration // 写响应的超时时间，可以在路由组中通过WriteTimeout覆盖
+	IdleTimeout       time.Duration // keep-alive连接的空闲超时时间
+	MaxHeaderBytes    int           // 请求头的最大字节数
+}
+
+// 从配置文件加载内嵌http服务的参数
+// 配置文件格式参见config.Server
+func (doris *Doris) LoadServerOptions(filename string) error {
+	var c config.Server
+	if err := config.LoadJson(filename, &c); err != nil {
+		return err
+	}
+	doris.ServerOptions = ServerOptions{
+		ReadTimeout:       time.Duration(c.ReadTimeout),
+		ReadHeaderTimeout: time.Duration(c.ReadHeaderTimeout),
+		WriteTimeout:      time.Duration(c.WriteTimeout),
+		IdleTimeout:       time.Duration(c.IdleTimeout),
+		MaxHeaderBytes:    c.MaxHeaderBytes,
+	}
+	return nil
+}
+
+// 运行框架程序绑定端口
+// 收到SIGINT/SIGTERM信号后优雅关闭
+func (doris *Doris) Run(addr ...string) error {
+	return doris.RunWithContext(context.Background(), addr...)
+}
+
+// 运行框架程序并在ctx结束或者收到SIGINT/SIGTERM信号时优雅关闭
+// 关闭时停止接收新连接，等待处理中的请求完成（最长ShutdownTimeout）
+// 然后执行通过OnShutdown注册的钩子函数
+func (doris *Doris) RunWithContext(ctx context.Context, addr ...string) error {
+	ln, err := net.Listen("tcp", ResolveAddress(addr))
+	if err != nil {
+		return err
+	}
+	return doris.runListener(ctx, ln)
+}
+
+// 以https方式运行框架程序
+// 客户端支持时自动协商使用HTTP/2
+func (doris *Doris) RunTLS(addr, certFile, keyFile string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := doris.newServer(ln.Addr().String())
+	doris.printStartup("https", ln.Addr())
+	return doris.serve(context.Background(), server, func() error {
+		return server.ServeTLS(ln, certFile, keyFile)
+	})
+}
+
+// 使用定制的tls配置运行框架程序
+// 用于客户端证书认证、自定义加密套件等场景，证书需要通过config提供
+func (doris *Doris) RunTLSConfig(addr string, config *tls.Config) error {
+	assert1(config != nil, "tls config can not be nil")
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := doris.newServer(ln.Addr().String())
+	server.TLSConfig = config
+	doris.printStartup("https", ln.Addr())
+	return doris.serve(context.Background(), server, func() error {
+		return server.ServeTLS(ln, "", "")
+	})
+}
+
+// 在unix domain socket上运行框架程序
+// 启动前会清理遗留的socket文件，退出后删除socket文件
+// 调用方式：doris.RunUnix("/var/run/doris.sock", 0666)
+func (doris *Doris) RunUnix(file string, mode os.FileMode) error {
+	// 只清理socket文件，防止误删普通文件
+	if fi, err := os.Lstat(file); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err = os.Remove(file); err != nil {
+			return err
+		}
+	}
+	ln, err := net.Listen("unix", file)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+	if err = os.Chmod(file, mode); err != nil {
+		ln.Close()
+		return err
+	}
+	return doris.RunListener(ln)
+}
+
+// 在已经打开的文件描述符上运行框架程序
+// 用于systemd的socket激活等场景
+func (doris *Doris) RunFd(fd int) error {
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("fd@%d", fd))
+	if f == nil {
+		return fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	// FileListener会复制描述符因此可以直接关闭f
+	ln, err := net.FileListener(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	return doris.RunListener(ln)
+}
+
+// 在指定的listener上运行框架程序
+func (doris *Doris) RunListener(ln net.Listener) error {
+	return doris.runListener(context.Background(), ln)
+}
+
+// 实际在listener上运行http服务
+func (doris *Doris) runListener(ctx context.Context, ln net.Listener) error {
+	server := doris.newServer(ln.Addr().String())
+	doris.printStartup("http", ln.Addr())
+	return doris.serve(ctx, server, func() error {
+		return server.Serve(ln)
+	})
+}
+
+// 注册关闭时执行的钩子函数，比如关闭数据库连接池、刷新日志等
+// 钩子函数在处理中的请求完成之后按注册顺序执行
+func (doris *Doris) OnShutdown(hooks ...func()) {
+	doris.shutdownHooks = append(doris.shutdownHooks, hooks...)
+}
+
+// 优雅关闭http服务
+// 停止接收新连接并等待处理中的请求完成，ctx结束时直接返回ctx的错误
+// 无论等待是否超时，钩子函数都会被执行且只执行一次
+// 可以在其他goroutine中调用，Run等方法会等到关闭完成后才返回
+func (doris *Doris) Shutdown(ctx context.Context) (err error) {
+	doris.serverMu.Lock()
+	server := doris.Server
+	if doris.shutdownDone == nil {
+		doris.shutdownDone = make(chan struct{})
+	}
+	done := doris.shutdownDone
+	doris.serverMu.Unlock()
+
+	if server != nil {
+		err = server.Shutdown(ctx)
+	}
+	doris.shutdownOnce.Do(func() {
+		for _, hook := range doris.shutdownHooks {
+			hook()
+		}
+		close(done)
+	})
+	return
+}
+
+// 创建内嵌的http服务
+func (doris *Doris) newServer(address string) *http.Server {
+	opts := doris.ServerOptions
+	server := &http.Server{
+		Addr:              address,
+		Handler:           doris,
+		ReadTimeout:       opts.ReadTimeout,
+		ReadHeaderTimeout: opts.ReadHeaderTimeout,
+		WriteTimeout:      opts.WriteTimeout,
+		IdleTimeout:       opts.IdleTimeout,
+		MaxHeaderBytes:    opts.MaxHeaderBytes,
+	}
+	// 开启h2c时同时支持明文HTTP/2
+	// 用于运行在终止tls的代理之后
+	if doris.EnableH2C {
+		protocols := new(http.Protocols)
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		server.Protocols = protocols
+	}
+	return server
+}
+
+// 启动服务并等待关闭信号
+func (doris *Doris) serve(ctx context.Context, server *http.Server, start func() error) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	doris.serverMu.Lock()
+	doris.Server = server
+	if doris.shutdownDone != nil {
+		// 已经调用过Shutdown，服务启动后立即关闭并释放listener
+		server.Shutdown(context.Background())
+	}
+	doris.serverMu.Unlock()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- start()
+	}()
+
+	select {
+	case err := <-errCh:
+		// 通过Shutdown关闭时不认为是错误
+		// 在其他goroutine中调用Shutdown时等待请求处理完成以及钩子函数执行完毕
+		if err == http.ErrServerClosed {
+			doris.serverMu.Lock()
+			done := doris.shutdownDone
+			doris.serverMu.Unlock()
+			if done != nil {
+				<-done
+			}
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	// 开始优雅关闭
+	sctx := context.Background()
+	if doris.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		sctx, cancel = context.WithTimeout(sctx, doris.ShutdownTimeout)
+		defer cancel()
+	}
+	return doris.Shutdown(sctx)
+}
+
+// 打印banner和启动信息
+func (doris *Doris) printStartup(scheme string, addr net.Addr) {
+	// 判断是否展示banner
+	if doris.ShowBanner {
+		// 显示banner信息
+		fmt.Printf(banner, Version, website)
+	}
+
+	// 打印实际监听的地址
+	address := addr.String()
+	if addr.Network() != "tcp" {
+		address = addr.Network() + ":" + address
+	}
+	fmt.Printf("⇨ %s server started on \033[0;32m%s\033[0m \n\n", scheme, address)
+}
@@ -0,0 +1,267 @@
+package doris
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 在随机端口上监听
+func listenLocal(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln
+}
+
+func TestShutdownFromAnotherGoroutine(t *testing.T) {
+	var finished, hooked atomic.Bool
+	started := make(chan struct{})
+	d := New()
+	d.GET("/slow", func(c *Context) error {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		finished.Store(true)
+		c.String(http.StatusOK, "done")
+		return nil
+	})
+	d.OnShutdown(func() {
+		time.Sleep(50 * time.Millisecond)
+		hooked.Store(finished.Load())
+	})
+
+	ln := listenLocal(t)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- d.RunListener(ln)
+	}()
+	bodyCh := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String() + "/slow")
+		if err != nil {
+			bodyCh <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		bodyCh <- string(b)
+	}()
+
+	<-started
+	go d.Shutdown(context.Background())
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("RunListener returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunListener did not return")
+	}
+	if !finished.Load() || !hooked.Load() {
+		t.Errorf("RunListener returned before draining (finished=%v, hooks after drain=%v)", finished.Load(), hooked.Load())
+	}
+	if body := <-bodyCh; body != "done" {
+		t.Errorf("in-flight request got %q", body)
+	}
+
+	// 关闭之后再次运行会立即返回
+	ln = listenLocal(t)
+	go func() {
+		errCh <- d.RunListener(ln)
+	}()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Errorf("second RunListener returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("second RunListener did not return")
+	}
+}
+
+// 生成127.0.0.1的自签名证书，返回证书和私钥文件路径
+func selfSignedCert(t *testing.T) (certFile, keyFile string, pool *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "doris test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return
+}
+
+// 在后台运行服务，返回实际监听的地址和等待服务退出的函数
+func startServer(t *testing.T, d *Doris, run func() error) (addr string, stop func()) {
+	t.Helper()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- run()
+	}()
+	for i := 0; i < 500; i++ {
+		d.serverMu.Lock()
+		if d.Server != nil {
+			addr = d.Server.Addr
+		}
+		d.serverMu.Unlock()
+		if addr != "" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if addr == "" {
+		t.Fatal("server did not start")
+	}
+	stop = func() {
+		if err := d.Shutdown(context.Background()); err != nil {
+			t.Errorf("Shutdown returned %v", err)
+		}
+		if err := <-errCh; err != nil {
+			t.Errorf("server returned %v", err)
+		}
+	}
+	return
+}
+
+// 返回请求使用的协议版本
+func protoHandler(c *Context) error {
+	c.String(http.StatusOK, c.Request.Proto)
+	return nil
+}
+
+// 使用指定协议的客户端发送请求，返回响应的协议版本和响应体
+func getWithProtocols(url string, tlsConfig *tls.Config, setup func(*http.Protocols)) (string, string, error) {
+	protocols := new(http.Protocols)
+	setup(protocols)
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig: tlsConfig,
+		Protocols:       protocols,
+	}}
+	defer client.CloseIdleConnections()
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	return resp.Proto, string(b), err
+}
+
+func TestRunTLS(t *testing.T) {
+	certFile, keyFile, pool := selfSignedCert(t)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runners := []struct {
+		name string
+		run  func(d *Doris) error
+	}{
+		{"RunTLS", func(d *Doris) error { return d.RunTLS("127.0.0.1:0", certFile, keyFile) }},
+		{"RunTLSConfig", func(d *Doris) error {
+			return d.RunTLSConfig("127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+		}},
+	}
+	clients := []struct {
+		name  string
+		setup func(*http.Protocols)
+		proto string
+	}{
+		{"http1", func(p *http.Protocols) { p.SetHTTP1(true) }, "HTTP/1.1"},
+		{"http2", func(p *http.Protocols) { p.SetHTTP2(true) }, "HTTP/2.0"},
+	}
+	for _, rr := range runners {
+		d := New()
+		d.GET("/proto", protoHandler)
+		addr, stop := startServer(t, d, func() error { return rr.run(d) })
+		for _, tt := range clients {
+			proto, body, err := getWithProtocols("https://"+addr+"/proto", &tls.Config{RootCAs: pool}, tt.setup)
+			if err != nil {
+				t.Errorf("%s %s: %v", rr.name, tt.name, err)
+				continue
+			}
+			if proto != tt.proto || body != tt.proto {
+				t.Errorf("%s %s: got response %s with body %q, want %s", rr.name, tt.name, proto, body, tt.proto)
+			}
+		}
+		stop()
+	}
+}
+
+func TestRunH2C(t *testing.T) {
+	h2c := func(p *http.Protocols) { p.SetUnencryptedHTTP2(true) }
+	http1 := func(p *http.Protocols) { p.SetHTTP1(true) }
+	tests := []struct {
+		enable bool
+		setup  func(*http.Protocols)
+		proto  string // 为空表示请求失败
+	}{
+		{true, h2c, "HTTP/2.0"},
+		{true, http1, "HTTP/1.1"},
+		{false, h2c, ""},
+		{false, http1, "HTTP/1.1"},
+	}
+	for _, tt := range tests {
+		d := New()
+		d.EnableH2C = tt.enable
+		d.GET("/proto", protoHandler)
+		ln := listenLocal(t)
+		addr, stop := startServer(t, d, func() error { return d.RunListener(ln) })
+		proto, body, err := getWithProtocols("http://"+addr+"/proto", nil, tt.setup)
+		switch {
+		case tt.proto == "" && err == nil:
+			t.Errorf("EnableH2C=%v: expected h2c request to fail, got %s", tt.enable, proto)
+		case tt.proto != "" && err != nil:
+			t.Errorf("EnableH2C=%v: %v", tt.enable, err)
+		case tt.proto != "" && (proto != tt.proto || body != tt.proto):
+			t.Errorf("EnableH2C=%v: got response %s with body %q, want %s", tt.enable, proto, body, tt.proto)
+		}
+		stop()
+	}
+}
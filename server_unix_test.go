@@ -0,0 +1,114 @@
+//go:build unix
+
+package doris
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// 通过unix socket发送请求的客户端
+func unixClient(file string) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", file)
+		},
+	}}
+}
+
+func getBody(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestRunUnix(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "doris.sock")
+	// 上次运行遗留的socket文件会被替换
+	stale, err := net.Listen("unix", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	d := New()
+	d.GET("/ping", func(c *Context) error {
+		c.String(http.StatusOK, "pong")
+		return nil
+	})
+	_, stop := startServer(t, d, func() error {
+		return d.RunUnix(file, 0660)
+	})
+	fi, err := os.Lstat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSocket == 0 || fi.Mode().Perm() != 0660 {
+		t.Errorf("got mode %v, want socket with 0660", fi.Mode())
+	}
+	if body := getBody(t, unixClient(file), "http://unix/ping"); body != "pong" {
+		t.Errorf("got %q, want %q", body, "pong")
+	}
+	stop()
+	if _, err := os.Lstat(file); !os.IsNotExist(err) {
+		t.Errorf("socket file not removed after shutdown: %v", err)
+	}
+}
+
+func TestRunUnixKeepsRegularFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(file, []byte("keep"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := New().RunUnix(file, 0660); err == nil {
+		t.Fatal("RunUnix on a regular file should fail")
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != "keep" {
+		t.Errorf("regular file changed: %q %v", data, err)
+	}
+}
+
+func TestRunFd(t *testing.T) {
+	ln := listenLocal(t)
+	f, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// RunFd会关闭传入的描述符，这里传递单独复制的描述符
+	fd, err := syscall.Dup(int(f.Fd()))
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	d := New()
+	d.GET("/ping", func(c *Context) error {
+		c.String(http.StatusOK, "pong")
+		return nil
+	})
+	_, stop := startServer(t, d, func() error {
+		return d.RunFd(fd)
+	})
+	defer stop()
+	if body := getBody(t, http.DefaultClient, "http://"+addr+"/ping"); body != "pong" {
+		t.Errorf("got %q, want %q", body, "pong")
+	}
+}
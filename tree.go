@@ -1,6 +1,7 @@
 package doris
 
 import (
+	"regexp"
 	"strings"
 )
 
@@ -30,6 +31,9 @@ type (
 		pList    Params        // 参数列表
 		handlers HandlersChain // 函数处理链
 		debug    bool          // debug开关
+
+		constraint string         // 参数节点的约束（比如int或者正则表达式）
+		matcher    *regexp.Regexp // 编译后的参数约束
 	}
 	// 保存节点值结构
 	nodeValue struct {
@@ -43,6 +47,17 @@ type (
 	Params   []string // 参数列表
 )
 
+// 预定义的参数约束类型
+var paramTypes = map[string]string{
+	"int":   `[-+]?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"float": `[-+]?[0-9]*\.?[0-9]+([eE][-+]?[0-9]+)?`,
+	"bool":  `true|false|1|0`,
+	"alpha": `[a-zA-Z]+`,
+	"alnum": `[a-zA-Z0-9]+`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+}
+
 // 路由类型常量
 const (
 	skind nodeType = iota // 常数（默认）
@@ -107,7 +122,7 @@ func (n *node) addRoute(path string, handlers HandlersChain) {
 		pList                        Params
 		tmpPList                     Params // 存储临时参数列表
 		tmpHandlers                  HandlersChain
-		param, fullPath              string
+		param, constraint, fullPath  string
 		i, j, lp, lc, counter, index int
 		cn, child                    *node
 	)
@@ -142,6 +157,7 @@ func (n *node) addRoute(path string, handlers HandlersChain) {
 				// 更新当前节点参数
 				cn.prefix = path
 				cn.handlers = handlers
+				cn.pList = pList
 				break
 			}
 			// 出现不等情况
@@ -151,10 +167,9 @@ func (n *node) addRoute(path string, handlers HandlersChain) {
 				debugPrintMessage("cn", cn, n.debug)
 				debugPrintMessage("path", path, n.debug)
 				debugPrintMessage("cn.parent", cn.parent, n.debug)
-				j = 0
-				for j = i + 1; j < lp && path[j] != '/'; j++ {
-					continue
-				}
+				// 解析参数名和约束
+				param, constraint, j = parseParam(path[i:])
+				j += i
 				// 裂变节点
 				debugPrintMessage("裂变前=====", "__print__", n.debug)
 				debugPrintMessage("cn", cn, n.debug)
@@ -164,9 +179,8 @@ func (n *node) addRoute(path string, handlers HandlersChain) {
 				debugPrintMessage("cn====", cn, n.debug)
 
 				// 提取参数
-				param = path[i+1 : j]
+				fullPath = cn.fullPath + path[i:j]
 				path = path[j:]
-				fullPath = cn.fullPath + ":" + param
 				// 更新参数
 				pList = append(pList, param)
 				// path到尾部
@@ -175,12 +189,14 @@ func (n *node) addRoute(path string, handlers HandlersChain) {
 					tmpPList = pList
 				}
 				// 给cn插入新节点并更新cn
-				// 先查找是否存在参数:节点
-				pChild := cn.findChildByKind(pkind)
+				// 先查找是否存在约束相同的参数:节点
+				pChild := cn.findParamNode(constraint)
 				if pChild == nil {
 					// debugPrintMessage("len(pChild.pList)", len(pChild.pList), n.debug)
 					// debugPrintMessage("pChild.pList", pChild.pList, n.debug)
 					pChild = cn.insertNode(pkind, ":", fullPath, children{}, tmpPList, tmpHandlers)
+					pChild.constraint = constraint
+					pChild.matcher = compileConstraint(constraint)
 				} else if path == "" {
 					// 使用新参数覆盖旧的
 					pChild.pList = tmpPList
@@ -193,9 +209,10 @@ func (n *node) addRoute(path string, handlers HandlersChain) {
 			} else if path[i] == '*' { // 全路由
 				// 裂变节点
 				cn.nodeFission(i)
-				// 插入全匹配节点
-				pList = append(pList, "*")
-				fullPath = cn.fullPath + "*"
+				// 插入全匹配节点，未命名时参数名为*
+				param, j = parseWildcard(path[i:])
+				pList = append(pList, param)
+				fullPath = cn.fullPath + path[i:i+j]
 				cn = cn.insertNode(akind, "*", fullPath, children{}, pList, handlers)
 				// 碰到*说明到达末尾跳出循环
 				break
@@ -206,12 +223,11 @@ func (n *node) addRoute(path string, handlers HandlersChain) {
 				// 裂变节点
 				cn.nodeFission(i)
 				path = path[i:]
-				indexP := strings.Index(path, ":")
-				indexA := strings.Index(path, "*")
-				if indexP == -1 && indexA == -1 { // 说明剩余的部分既不含:也不含*
+				index = wildcardIndex(path)
+				if index == -1 { // 说明剩余的部分既不含:也不含*
 					fullPath = cn.fullPath + path
 					// 插入静态节点
-					cn = cn.insertNode(skind, path, fullPath, children{}, Params{}, handlers)
+					cn = cn.insertNode(skind, path, fullPath, children{}, pList, handlers)
 					// path已经处理完
 					break
 				} else { // 含:或者*的提取:或者*之前的部分
@@ -224,11 +240,6 @@ func (n *node) addRoute(path string, handlers HandlersChain) {
 						// 含有子节点
 						cn = child
 					} else {
-						if indexP > indexA {
-							index = indexP
-						} else {
-							index = indexA
-						}
 						prefix := path[:index]
 						fullPath = cn.fullPath + path[:index]
 						path = path[index:]
@@ -251,6 +262,7 @@ func (n *node) addRoute(path string, handlers HandlersChain) {
 			if path == "" {
 				if len(cn.handlers) == 0 {
 					cn.handlers = handlers
+					cn.pList = pList
 				}
 				break
 			}
@@ -258,27 +270,23 @@ func (n *node) addRoute(path string, handlers HandlersChain) {
 			// len(cn.handlers) == 0 解决只有一个分支的时候下面是参数节点
 			// 上面节点合并/导致路由错误的问题。
 			if len(cn.children) == 0 && len(cn.handlers) == 0 {
-				indexP := strings.Index(path, ":")
-				indexA := strings.Index(path, "*")
-				if indexP == -1 && indexA == -1 {
+				index = wildcardIndex(path)
+				if index == -1 {
 					if len(cn.handlers) == 0 {
 						cn.prefix += path
 						cn.fullPath += path
 						if len(handlers) != 0 {
 							cn.handlers = handlers
+							cn.pList = pList
 						}
 						debugPrintMessage("当前节点handlers", "__print__", n.debug)
 						debugPrintMessage("cn.handlers", cn.handlers, n.debug)
 					} else { // 插入新节点
 						debugPrintMessage("path", path, n.debug)
 						debugPrintMessage("cn.fullPath", cn.fullPath, n.debug)
-						_ = cn.insertNode(skind, prefix, fullPath, children{}, Params{}, handlers)
+						_ = cn.insertNode(skind, prefix, fullPath, children{}, pList, handlers)
 					}
 					break
-				} else if indexP > indexA { // 包含:
-					index = indexP
-				} else { // 包含*
-					index = indexA
 				}
 				// 更新当前节点的参数
 				// 更新path参数
@@ -419,6 +427,117 @@ func (n *node) findChildrenByKind(kind nodeType) []*node {
 	return cns
 }
 
+// 查找约束相同的参数子节点
+func (n *node) findParamNode(constraint string) *node {
+	for _, child := range n.children {
+		if child.nType == pkind && child.constraint == constraint {
+			return child
+		}
+	}
+	return nil
+}
+
+// 根据参数值查找全部可以匹配的参数子节点
+// 约束匹配的节点按注册顺序排在前面，无约束的节点排在最后
+func (n *node) findParamChildren(value string) []*node {
+	var (
+		matched  []*node
+		fallback *node
+	)
+	for _, child := range n.children {
+		if child.nType != pkind {
+			continue
+		}
+		if child.matcher == nil {
+			if fallback == nil {
+				fallback = child
+			}
+			continue
+		}
+		if child.matcher.MatchString(value) {
+			matched = append(matched, child)
+		}
+	}
+	if fallback != nil {
+		matched = append(matched, fallback)
+	}
+	return matched
+}
+
+// 解析以':'开头的参数，支持:name<constraint>形式的约束
+// 返回参数名、约束以及参数部分的长度
+func parseParam(path string) (name, constraint string, end int) {
+	end = 1
+	for end < len(path) && path[end] != '/' && path[end] != '<' {
+		end++
+	}
+	name = path[1:end]
+	assert1(name != "", "param name can not be empty in path '"+path+"'")
+	if end == len(path) || path[end] != '<' {
+		return
+	}
+	// 约束以'>'结束且后面紧跟'/'或者路径结尾
+	for k := end + 1; k < len(path); k++ {
+		if path[k] == '>' && (k+1 == len(path) || path[k+1] == '/') {
+			return name, path[end+1 : k], k + 1
+		}
+	}
+	panic("unclosed constraint for param '" + name + "' in path '" + path + "'")
+}
+
+// 解析以'*'开头的全匹配参数，比如*filepath
+// 返回参数名以及参数部分的长度，未命名时参数名为*
+func parseWildcard(path string) (name string, end int) {
+	end = 1
+	for end < len(path) && path[end] != '/' {
+		end++
+	}
+	name = path[1:end]
+	if name == "" {
+		name = "*"
+	}
+	return
+}
+
+// 返回路径中按顺序出现的参数名
+func pathParamNames(path string) []string {
+	var names []string
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case ':':
+			name, _, end := parseParam(path[i:])
+			names = append(names, name)
+			i += end - 1
+		case '*':
+			name, _ := parseWildcard(path[i:])
+			return append(names, name)
+		}
+	}
+	return names
+}
+
+// 获取第一个:或者*的位置
+// 参数约束总是在:之后，因此约束中的字符不会影响结果
+func wildcardIndex(path string) int {
+	return strings.IndexAny(path, ":*")
+}
+
+// 编译参数约束，支持预定义的类型和正则表达式
+func compileConstraint(constraint string) *regexp.Regexp {
+	if constraint == "" {
+		return nil
+	}
+	expr, ok := paramTypes[constraint]
+	if !ok {
+		expr = constraint
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		panic("invalid param constraint '" + constraint + "': " + err.Error())
+	}
+	return re
+}
+
 // 查找路由
 func (n *node) find(path string) (nv *nodeValue) {
 	// 查找具体路由
@@ -466,7 +585,9 @@ func (n *node) find(path string) (nv *nodeValue) {
 		}
 		debugPrintMessage("lp", lp, n.debug)
 		debugPrintMessage("l", l, n.debug)
-		if l == lp {
+		if cn.nType == pkind {
+			// 参数节点的值已经在参数处理子流程中取出
+		} else if l == lp {
 			// 已经到达前缀结束
 			search = search[l:] // 更新search
 			debugPrintMessage("到达当前节点前缀末尾", "__print__", n.debug)
@@ -517,7 +638,28 @@ func (n *node) find(path string) (nv *nodeValue) {
 	paramProcess:
 		// param路由处理子流程
 		debugPrintMessage("参数处理子流程", "__print__", n.debug)
-		if child = cn.findChildByKind(pkind); child != nil {
+		// 获取参数值
+		l = len(search)
+		i = 0 // 重新初始化i防止溢出
+		//			debugPrintMessage("----i", i, n.debug)
+		for ; i < l && search[i] != '/'; i++ {
+			// 跳过中间部分
+		}
+		// 根据参数值的约束选择参数节点
+		// 多个参数节点都匹配时依次尝试，子树中未找到路由时回退到下一个节点
+		child = nil
+		if candidates := cn.findParamChildren(search[:i]); len(candidates) > 0 {
+			for _, candidate := range candidates[:len(candidates)-1] {
+				if sub := candidate.find(search[i:]); sub != nil {
+					values := make([]interface{}, 0, len(pvalues)+1+len(sub.pvalues))
+					values = append(append(values, pvalues...), search[:i])
+					sub.pvalues = append(values, sub.pvalues...)
+					return sub
+				}
+			}
+			child = candidates[len(candidates)-1]
+		}
+		if child != nil {
 			// Issue #378 Fix routing with slash included in parameter value
 			// 这个地方待验证
 			if len(pvalues) == count {
@@ -531,13 +673,6 @@ func (n *node) find(path string) (nv *nodeValue) {
 				nn = cn     // 下一个节点设置为当前节点
 				ns = search // 下一个节点的搜索关键词
 			}
-			// 获取参数值
-			l = len(search)
-			i = 0 // 重新初始化i防止溢出
-			//			debugPrintMessage("----i", i, n.debug)
-			for ; i < l && search[i] != '/'; i++ {
-				// 跳过中间部分
-			}
 			debugPrintMessage("cn", cn, n.debug)
 			debugPrintMessage("child", child, n.debug)
 			debugPrintMessage("search", search, n.debug)
@@ -1 +1,104 @@
 package doris
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// 发送请求并返回响应
+func performRequest(d *Doris, method, path string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	d.ServeHTTP(w, req)
+	return w
+}
+
+// 返回路由名和按名称排序的参数，用于比较匹配结果
+func routeEcho(name string) HandlerFunc {
+	return func(c *Context) error {
+		keys := make([]string, 0, len(c.params))
+		for k := range c.params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := []string{name}
+		for _, k := range keys {
+			parts = append(parts, fmt.Sprintf("%s=%v", k, c.params[k]))
+		}
+		c.String(http.StatusOK, strings.Join(parts, " "))
+		return nil
+	}
+}
+
+func TestParamConstraintDispatch(t *testing.T) {
+	d := New()
+	d.GET("/users/:id<int>", routeEcho("user.id"))
+	d.GET("/users/:name<alpha>", routeEcho("user.name"))
+	d.GET("/files/:name<[a-z]+\\.txt>", routeEcho("file"))
+	d.GET("/p/:id<int>/posts", routeEcho("posts"))
+	d.GET("/p/:name/profile", routeEcho("profile"))
+	d.GET("/q/:id<int>/x", routeEcho("q.int"))
+	d.GET("/q/:code<alnum>/x/y", routeEcho("q.alnum"))
+	d.GET("/q/:any/x/y/z", routeEcho("q.any"))
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/users/42", 200, "user.id id=42"},
+		{"/users/bob", 200, "user.name name=bob"},
+		{"/users/bob42", 404, ""},
+		{"/files/readme.txt", 200, "file name=readme.txt"},
+		{"/files/readme.md", 404, ""},
+		{"/p/5/posts", 200, "posts id=5"},
+		{"/p/bob/profile", 200, "profile name=bob"},
+		// 约束节点的子树未命中时回退到其他参数节点
+		{"/p/5/profile", 200, "profile name=5"},
+		{"/p/bob/posts", 404, ""},
+		{"/q/7/x", 200, "q.int id=7"},
+		{"/q/7/x/y", 200, "q.alnum code=7"},
+		{"/q/7/x/y/z", 200, "q.any any=7"},
+		{"/q/a-b/x/y/z", 200, "q.any any=a-b"},
+	}
+	for _, tt := range tests {
+		w := performRequest(d, "GET", tt.path)
+		if w.Code != tt.code {
+			t.Errorf("GET %s: code = %d, want %d", tt.path, w.Code, tt.code)
+			continue
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("GET %s: body = %q, want %q", tt.path, w.Body.String(), tt.body)
+		}
+	}
+}
+
+func TestParseParam(t *testing.T) {
+	tests := []struct {
+		path       string
+		name       string
+		constraint string
+		end        int
+	}{
+		{":id", "id", "", 3},
+		{":id/posts", "id", "", 3},
+		{":id<int>", "id", "int", 8},
+		{":id<int>/posts", "id", "int", 8},
+		{":name<[a-z]+\\.txt>", "name", "[a-z]+\\.txt", 18},
+		{":v<a>b>/x", "v", "a>b", 7},
+	}
+	for _, tt := range tests {
+		name, constraint, end := parseParam(tt.path)
+		if name != tt.name || constraint != tt.constraint || end != tt.end {
+			t.Errorf("parseParam(%q) = %q, %q, %d, want %q, %q, %d",
+				tt.path, name, constraint, end, tt.name, tt.constraint, tt.end)
+		}
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"os"
 	"path"
 	"reflect"
+	"runtime"
+	"strings"
 )
 
 // 连接路径公用方法
@@ -28,6 +30,54 @@ func JoinPaths(absolutePath, relativePath string) string {
 	return finalPath
 }
 
+// 清理路径中多余的'/'以及'.'和'..'，保留结尾的'/'
+func CleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	np := path.Clean(p)
+	if lastChar(p) == '/' && np != "/" {
+		np += "/"
+	}
+	return np
+}
+
+// 忽略大小写将路径和路由模式逐段比对
+// 匹配时返回使用路由中静态部分大小写的路径，参数部分保持原样
+func matchPathFold(pattern, p string) (string, bool) {
+	pSegs := strings.Split(pattern, "/")
+	segs := strings.Split(p, "/")
+	fixed := make([]string, 0, len(segs))
+	for i, ps := range pSegs {
+		// 全匹配吸收剩余的全部部分
+		if strings.HasPrefix(ps, "*") {
+			if i >= len(segs) {
+				return "", false
+			}
+			fixed = append(fixed, segs[i:]...)
+			return strings.Join(fixed, "/"), true
+		}
+		if i >= len(segs) {
+			return "", false
+		}
+		switch {
+		case strings.HasPrefix(ps, ":"):
+			fixed = append(fixed, segs[i])
+		case strings.EqualFold(ps, segs[i]):
+			fixed = append(fixed, ps)
+		default:
+			return "", false
+		}
+	}
+	if len(pSegs) != len(segs) {
+		return "", false
+	}
+	return strings.Join(fixed, "/"), true
+}
+
 // 解析网络地址
 func ResolveAddress(addr []string) string {
 	switch len(addr) {
@@ -92,6 +142,11 @@ func debugPrintMessage(name string, value interface{}, debug bool) {
 	}
 }
 
+// 获取函数名
+func nameOfFunction(f interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
+
 // 判断interface类型的动态值是否为nil
 func IsNil(i interface{}) bool {
 	defer func() {
@@ -0,0 +1,549 @@
+// 基于Response.Hijack实现的websocket(RFC 6455)
+package doris
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pxlh007/doris/internal/json"
+)
+
+// websocket消息类型，和RFC 6455中的opcode一致
+const (
+	WSText   = 1  // 文本消息
+	WSBinary = 2  // 二进制消息
+	WSClose  = 8  // 关闭帧
+	WSPing   = 9  // ping帧
+	WSPong   = 10 // pong帧
+
+	wsContinuation = 0 // 分片消息的后续帧
+)
+
+// websocket关闭状态码
+const (
+	WSCloseNormal             = 1000 // 正常关闭
+	WSCloseGoingAway          = 1001 // 服务端关闭或者浏览器离开页面
+	WSCloseProtocolError      = 1002 // 协议错误
+	WSCloseUnsupportedData    = 1003 // 不支持的数据类型
+	WSCloseNoStatus           = 1005 // 关闭帧中没有状态码，不能主动发送
+	WSCloseAbnormal           = 1006 // 连接异常断开，不能主动发送
+	WSCloseInvalidPayload     = 1007 // 消息数据不合法，比如文本消息不是utf-8编码
+	WSClosePolicyViolation    = 1008 // 违反策略
+	WSCloseMessageTooBig      = 1009 // 消息超过大小限制
+	WSCloseMandatoryExtension = 1010 // 客户端需要的扩展服务端不支持
+	WSCloseInternalError      = 1011 // 服务端内部错误
+)
+
+const (
+	wsGUID              = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11" // 计算Sec-WebSocket-Accept使用的GUID
+	wsMaxControlPayload = 125                                    // 控制帧的最大数据长度
+	defaultWSReadLimit  = 1 << 20                                // 默认的单个消息最大字节数
+)
+
+// 连接已经发送过关闭帧
+var errWSClosed = errors.New("websocket: close frame already sent")
+
+type (
+	// websocket处理函数
+	// 返回nil时以1000正常关闭连接，返回错误时以1011关闭连接并记录日志
+	WSHandler func(*WSConn) error
+
+	// websocket握手参数
+	WSUpgrader struct {
+		ReadLimit    int64                      // 单个消息的最大字节数，为0时使用默认值，小于0时不限制
+		Subprotocols []string                   // 服务端支持的子协议，按优先级排列
+		CheckOrigin  func(r *http.Request) bool // 校验Origin头，为空时只允许同源请求
+	}
+
+	// websocket连接
+	// 读和写可以分别在不同的goroutine中进行，但同一时间只能有一个goroutine读
+	WSConn struct {
+		Context     *Context      // 握手请求的上下文，处理函数返回前有效
+		conn        net.Conn      // 被劫持的底层连接
+		br          *bufio.Reader // 读缓冲，可能包含握手之后客户端立即发送的数据
+		bw          *bufio.Writer // 写缓冲
+		writeMu     sync.Mutex    // 保证帧的写入不交错
+		closeSent   bool          // 是否已经发送关闭帧
+		closeOnce   sync.Once     // 保证底层连接只关闭一次
+		readLimit   int64         // 单个消息的最大字节数
+		subprotocol string        // 协商的子协议
+		pongHandler func([]byte)  // 收到pong帧时的回调
+	}
+
+	// 连接因关闭帧或者协议错误而结束
+	WSCloseError struct {
+		Code   int    // 关闭状态码
+		Reason string // 关闭原因
+	}
+)
+
+// 注册websocket路由，使用默认的握手参数
+// 需要定制握手参数时使用：router.GET(path, (&doris.WSUpgrader{...}).Handler(handler))
+// 调用方式：router.WebSocket("/ws", func(ws *doris.WSConn) error {...})
+func (group *RouteGroup) WebSocket(relativePath string, handler WSHandler) IRoutes {
+	assert1(handler != nil, "websocket handler can not be nil")
+	return group.handle(http.MethodGet, relativePath, (&WSUpgrader{}).Handler(handler))
+}
+
+// 将websocket处理函数包装为HandlerFunc
+// 握手失败时返回的错误交给集中式错误处理器处理
+func (u *WSUpgrader) Handler(handler WSHandler) HandlerFunc {
+	return func(c *Context) error {
+		ws, err := u.Upgrade(c)
+		if err != nil {
+			return err
+		}
+		// 握手之后无法再返回http响应，只能通过关闭帧通知客户端
+		err = handler(ws)
+		if err == nil {
+			ws.Close(WSCloseNormal, "")
+			return nil
+		}
+		if _, ok := err.(*WSCloseError); !ok && err != io.EOF {
+			c.Doris.Logger.Error(err.Error())
+		}
+		ws.Close(WSCloseInternalError, "")
+		return nil
+	}
+}
+
+// 完成websocket握手并劫持底层连接
+func (u *WSUpgrader) Upgrade(c *Context) (*WSConn, error) {
+	r := c.Request
+	if r.Method != http.MethodGet || !r.ProtoAtLeast(1, 1) {
+		return nil, NewHTTPError(http.StatusBadRequest, "websocket: handshake requires HTTP/1.1 GET")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return nil, NewHTTPError(http.StatusBadRequest, "websocket: not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		c.Response.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, NewHTTPError(http.StatusUpgradeRequired, "websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, NewHTTPError(http.StatusBadRequest, "websocket: invalid Sec-WebSocket-Key")
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = wsSameOrigin
+	}
+	if !checkOrigin(r) {
+		return nil, NewHTTPError(http.StatusForbidden, "websocket: origin not allowed")
+	}
+
+	// 不支持劫持时（比如HTTP/2）返回http.ErrNotSupported
+	conn, brw, err := c.Response.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// 劫持后的连接可能还带着http服务设置的超时时间
+	conn.SetDeadline(time.Time{})
+
+	subprotocol := u.selectSubprotocol(r)
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: ")
+	brw.WriteString(wsAcceptKey(key))
+	if subprotocol != "" {
+		brw.WriteString("\r\nSec-WebSocket-Protocol: " + subprotocol)
+	}
+	brw.WriteString("\r\n\r\n")
+	if err = brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.Response.status = http.StatusSwitchingProtocols
+
+	readLimit := u.ReadLimit
+	if readLimit == 0 {
+		readLimit = defaultWSReadLimit
+	}
+	return &WSConn{
+		Context:     c,
+		conn:        conn,
+		br:          brw.Reader,
+		bw:          brw.Writer,
+		readLimit:   readLimit,
+		subprotocol: subprotocol,
+	}, nil
+}
+
+// 按服务端的优先级选择客户端也支持的子协议
+func (u *WSUpgrader) selectSubprotocol(r *http.Request) string {
+	for _, protocol := range u.Subprotocols {
+		if headerContainsToken(r.Header, "Sec-WebSocket-Protocol", protocol) {
+			return protocol
+		}
+	}
+	return ""
+}
+
+// 默认只允许同源的请求，没有Origin头的非浏览器客户端不受限制
+func wsSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// 计算Sec-WebSocket-Accept的值
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// 判断以逗号分隔的头信息中是否包含指定的值，忽略大小写
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 读取一个完整的消息，分片消息会被合并
+// ping帧自动回复pong，收到关闭帧时回复关闭帧并返回*WSCloseError
+func (ws *WSConn) ReadMessage() (messageType int, data []byte, err error) {
+	for {
+		remaining := int64(math.MaxInt64)
+		if ws.readLimit > 0 {
+			remaining = ws.readLimit - int64(len(data))
+		}
+		fin, opcode, payload, err := ws.readFrame(remaining)
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case WSPing:
+			if err = ws.writeFrame(true, WSPong, payload); err != nil && err != errWSClosed {
+				return 0, nil, err
+			}
+			continue
+		case WSPong:
+			if ws.pongHandler != nil {
+				ws.pongHandler(payload)
+			}
+			continue
+		case WSClose:
+			return 0, nil, ws.handleClose(payload)
+		case WSText, WSBinary:
+			if messageType != 0 {
+				return 0, nil, ws.fail(WSCloseProtocolError, "expected continuation frame")
+			}
+			messageType, data = opcode, payload
+		case wsContinuation:
+			if messageType == 0 {
+				return 0, nil, ws.fail(WSCloseProtocolError, "unexpected continuation frame")
+			}
+			data = append(data, payload...)
+		default:
+			return 0, nil, ws.fail(WSCloseProtocolError, fmt.Sprintf("unknown opcode %d", opcode))
+		}
+		if fin {
+			if messageType == WSText && !utf8.Valid(data) {
+				return 0, nil, ws.fail(WSCloseInvalidPayload, "invalid utf-8 in text message")
+			}
+			return messageType, data, nil
+		}
+	}
+}
+
+// 读取一个消息并按json解码到v中
+func (ws *WSConn) ReadJSON(v interface{}) error {
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// 发送一个消息，messageType可以是WSText、WSBinary、WSPing和WSPong
+// 关闭连接使用Close
+func (ws *WSConn) WriteMessage(messageType int, data []byte) error {
+	switch messageType {
+	case WSText, WSBinary:
+	case WSPing, WSPong:
+		if len(data) > wsMaxControlPayload {
+			return errors.New("websocket: control frame payload too long")
+		}
+	default:
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	return ws.writeFrame(true, messageType, data)
+}
+
+// 发送文本消息
+func (ws *WSConn) WriteText(text string) error {
+	return ws.WriteMessage(WSText, []byte(text))
+}
+
+// 将v编码为json后作为文本消息发送
+func (ws *WSConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.WriteMessage(WSText, data)
+}
+
+// 以分片的方式发送一个消息，每次Write发送一帧，Close时结束消息
+// 分片消息发送完成之前不能发送其他数据消息
+func (ws *WSConn) NextWriter(messageType int) (io.WriteCloser, error) {
+	if messageType != WSText && messageType != WSBinary {
+		return nil, fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	return &wsWriter{ws: ws, opcode: messageType}, nil
+}
+
+// 发送ping帧，客户端的pong通过SetPongHandler接收
+func (ws *WSConn) Ping(data []byte) error {
+	return ws.WriteMessage(WSPing, data)
+}
+
+// 设置收到pong帧时的回调，在ReadMessage所在的goroutine中执行
+func (ws *WSConn) SetPongHandler(h func(data []byte)) {
+	ws.pongHandler = h
+}
+
+// 设置单个消息的最大字节数，超过时以1009关闭连接，小于等于0时不限制
+func (ws *WSConn) SetReadLimit(limit int64) {
+	ws.readLimit = limit
+}
+
+// 设置读超时时间
+func (ws *WSConn) SetReadDeadline(t time.Time) error {
+	return ws.conn.SetReadDeadline(t)
+}
+
+// 设置写超时时间
+func (ws *WSConn) SetWriteDeadline(t time.Time) error {
+	return ws.conn.SetWriteDeadline(t)
+}
+
+// 协商的子协议
+func (ws *WSConn) Subprotocol() string {
+	return ws.subprotocol
+}
+
+// 客户端地址
+func (ws *WSConn) RemoteAddr() net.Addr {
+	return ws.conn.RemoteAddr()
+}
+
+// 发送关闭帧并关闭底层连接，可以重复调用
+func (ws *WSConn) Close(code int, reason string) error {
+	err := ws.writeFrame(true, WSClose, closePayload(code, reason))
+	if err == errWSClosed {
+		err = nil
+	}
+	ws.closeOnce.Do(func() {
+		if cerr := ws.conn.Close(); err == nil {
+			err = cerr
+		}
+	})
+	return err
+}
+
+// 处理客户端发送的关闭帧
+func (ws *WSConn) handleClose(payload []byte) error {
+	code, reason := WSCloseNoStatus, ""
+	if len(payload) == 1 {
+		return ws.fail(WSCloseProtocolError, "invalid close frame")
+	}
+	if len(payload) >= 2 {
+		code = int(binary.BigEndian.Uint16(payload))
+		reason = string(payload[2:])
+		if !validCloseCode(code) || !utf8.ValidString(reason) {
+			return ws.fail(WSCloseProtocolError, "invalid close frame")
+		}
+	}
+	// 回复关闭帧后关闭连接
+	reply := code
+	if code == WSCloseNoStatus {
+		reply = WSCloseNormal
+	}
+	ws.Close(reply, "")
+	return &WSCloseError{Code: code, Reason: reason}
+}
+
+// 客户端违反协议时发送关闭帧并关闭连接
+func (ws *WSConn) fail(code int, reason string) error {
+	ws.Close(code, reason)
+	return &WSCloseError{Code: code, Reason: reason}
+}
+
+// 读取一帧数据，remaining为当前消息还可以读取的字节数
+func (ws *WSConn) readFrame(remaining int64) (fin bool, opcode int, payload []byte, err error) {
+	var head [8]byte
+	if _, err = io.ReadFull(ws.br, head[:2]); err != nil {
+		return
+	}
+	fin = head[0]&0x80 != 0
+	opcode = int(head[0] & 0x0f)
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7f)
+
+	// 没有协商任何扩展，保留位必须为0
+	if head[0]&0x70 != 0 {
+		err = ws.fail(WSCloseProtocolError, "reserved bits set")
+		return
+	}
+	switch length {
+	case 126:
+		if _, err = io.ReadFull(ws.br, head[:2]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(head[:2]))
+	case 127:
+		if _, err = io.ReadFull(ws.br, head[:8]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(head[:8])
+	}
+	// 客户端发送的帧必须使用掩码
+	if !masked {
+		err = ws.fail(WSCloseProtocolError, "client frame is not masked")
+		return
+	}
+	if opcode >= WSClose {
+		if !fin || length > wsMaxControlPayload {
+			err = ws.fail(WSCloseProtocolError, "invalid control frame")
+			return
+		}
+	} else if length > math.MaxInt64 || int64(length) > remaining {
+		err = ws.fail(WSCloseMessageTooBig, "message too big")
+		return
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(ws.br, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(ws.br, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i&3]
+	}
+	return
+}
+
+// 写入一帧数据，服务端发送的帧不使用掩码
+func (ws *WSConn) writeFrame(fin bool, opcode int, data []byte) error {
+	ws.writeMu.Lock()
+	defer ws.writeMu.Unlock()
+	if ws.closeSent {
+		return errWSClosed
+	}
+	if opcode == WSClose {
+		ws.closeSent = true
+	}
+
+	var head [10]byte
+	head[0] = byte(opcode)
+	if fin {
+		head[0] |= 0x80
+	}
+	n := 2
+	switch length := len(data); {
+	case length <= wsMaxControlPayload:
+		head[1] = byte(length)
+	case length <= math.MaxUint16:
+		head[1] = 126
+		binary.BigEndian.PutUint16(head[2:], uint16(length))
+		n = 4
+	default:
+		head[1] = 127
+		binary.BigEndian.PutUint64(head[2:], uint64(length))
+		n = 10
+	}
+	ws.bw.Write(head[:n])
+	ws.bw.Write(data)
+	return ws.bw.Flush()
+}
+
+// 关闭帧的数据为2字节的状态码加上原因
+func closePayload(code int, reason string) []byte {
+	if code == WSCloseNoStatus {
+		return nil
+	}
+	// 控制帧的数据不能超过125字节
+	if len(reason) > wsMaxControlPayload-2 {
+		reason = reason[:wsMaxControlPayload-2]
+	}
+	payload := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	copy(payload[2:], reason)
+	return payload
+}
+
+// 判断关闭帧中的状态码是否合法
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003:
+		return true
+	case code >= 1007 && code <= 1014:
+		return true
+	case code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+// 实现error接口
+func (e *WSCloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Reason)
+}
+
+// 分片消息的写入器
+type wsWriter struct {
+	ws     *WSConn
+	opcode int  // 第一帧为消息类型，之后为后续帧
+	closed bool // 消息是否已经结束
+}
+
+// 每次写入发送一帧
+func (w *wsWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("websocket: write to closed writer")
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := w.ws.writeFrame(false, w.opcode, p); err != nil {
+		return 0, err
+	}
+	w.opcode = wsContinuation
+	return len(p), nil
+}
+
+// 发送结束帧
+func (w *wsWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.ws.writeFrame(true, w.opcode, nil)
+}
@@ -0,0 +1,311 @@
+package doris
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// 测试用的websocket客户端连接
+type wsTestConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// 发送握手请求，返回握手响应和连接
+func wsTestDial(t *testing.T, server *httptest.Server, path string, headers ...string) (*http.Response, *wsTestConn) {
+	t.Helper()
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for i := 0; i+1 < len(headers); i += 2 {
+		if headers[i+1] == "" {
+			req.Header.Del(headers[i])
+			continue
+		}
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return resp, &wsTestConn{conn: conn, br: br}
+}
+
+// 发送一帧，客户端的帧默认使用掩码
+func (c *wsTestConn) writeFrame(fin bool, opcode int, payload []byte, unmasked ...bool) {
+	var b bytes.Buffer
+	head := byte(opcode)
+	if fin {
+		head |= 0x80
+	}
+	b.WriteByte(head)
+	mask := byte(0x80)
+	if len(unmasked) > 0 && unmasked[0] {
+		mask = 0
+	}
+	switch n := len(payload); {
+	case n <= 125:
+		b.WriteByte(mask | byte(n))
+	case n <= 0xffff:
+		b.WriteByte(mask | 126)
+		binary.Write(&b, binary.BigEndian, uint16(n))
+	default:
+		b.WriteByte(mask | 127)
+		binary.Write(&b, binary.BigEndian, uint64(n))
+	}
+	if mask != 0 {
+		key := []byte{0x12, 0x34, 0x56, 0x78}
+		b.Write(key)
+		for i, v := range payload {
+			b.WriteByte(v ^ key[i&3])
+		}
+	} else {
+		b.Write(payload)
+	}
+	c.conn.Write(b.Bytes())
+}
+
+// 读取服务端发送的一帧
+func (c *wsTestConn) readFrame(t *testing.T) (fin bool, opcode int, payload []byte) {
+	t.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	if head[1]&0x80 != 0 {
+		t.Fatal("server frame is masked")
+	}
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var n uint16
+		binary.Read(c.br, binary.BigEndian, &n)
+		length = uint64(n)
+	case 127:
+		binary.Read(c.br, binary.BigEndian, &length)
+	}
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+	return head[0]&0x80 != 0, int(head[0] & 0x0f), payload
+}
+
+// 读取关闭帧并确认连接随后被关闭
+func (c *wsTestConn) readClose(t *testing.T) int {
+	t.Helper()
+	_, opcode, payload := c.readFrame(t)
+	if opcode != WSClose {
+		t.Fatalf("got opcode %d, want close frame", opcode)
+	}
+	if _, err := c.br.ReadByte(); err != io.EOF {
+		t.Errorf("connection not closed after close frame: %v", err)
+	}
+	if len(payload) < 2 {
+		return WSCloseNoStatus
+	}
+	return int(binary.BigEndian.Uint16(payload))
+}
+
+// 回显服务，文本消息原样返回，二进制消息分两片返回
+func wsEchoServer(upgrader *WSUpgrader) *httptest.Server {
+	d := New()
+	d.GET("/echo", upgrader.Handler(func(ws *WSConn) error {
+		for {
+			mt, data, err := ws.ReadMessage()
+			if err != nil {
+				return err
+			}
+			switch {
+			case string(data) == "fail":
+				return errors.New("handler failed")
+			case string(data) == "bye":
+				return nil
+			case mt == WSText:
+				err = ws.WriteText(string(data))
+			default:
+				w, _ := ws.NextWriter(WSBinary)
+				w.Write(data[:len(data)/2])
+				w.Write(data[len(data)/2:])
+				err = w.Close()
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}))
+	return httptest.NewServer(d)
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	d := New()
+	upgrader := &WSUpgrader{Subprotocols: []string{"v2", "v1"}}
+	d.GET("/ws", upgrader.Handler(func(ws *WSConn) error {
+		return ws.WriteText(ws.Subprotocol())
+	}))
+	server := httptest.NewServer(d)
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		headers []string
+		code    int
+	}{
+		{"valid", nil, http.StatusSwitchingProtocols},
+		{"subprotocol", []string{"Sec-WebSocket-Protocol", "v1, v2"}, http.StatusSwitchingProtocols},
+		{"same origin", []string{"Origin", server.URL}, http.StatusSwitchingProtocols},
+		{"no upgrade", []string{"Upgrade", ""}, http.StatusBadRequest},
+		{"bad version", []string{"Sec-WebSocket-Version", "8"}, http.StatusUpgradeRequired},
+		{"bad key", []string{"Sec-WebSocket-Key", "short"}, http.StatusBadRequest},
+		{"cross origin", []string{"Origin", "http://evil.example"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		resp, c := wsTestDial(t, server, "/ws", tt.headers...)
+		if resp.StatusCode != tt.code {
+			t.Errorf("%s: got status %d, want %d", tt.name, resp.StatusCode, tt.code)
+			continue
+		}
+		if tt.code != http.StatusSwitchingProtocols {
+			continue
+		}
+		if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+			t.Errorf("%s: got Sec-WebSocket-Accept %q", tt.name, got)
+		}
+		want := ""
+		if tt.name == "subprotocol" {
+			want = "v2"
+		}
+		if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != want {
+			t.Errorf("%s: got subprotocol %q, want %q", tt.name, got, want)
+		}
+		if _, _, payload := c.readFrame(t); string(payload) != want {
+			t.Errorf("%s: handler saw subprotocol %q, want %q", tt.name, payload, want)
+		}
+		if code := c.readClose(t); code != WSCloseNormal {
+			t.Errorf("%s: got close code %d, want %d", tt.name, code, WSCloseNormal)
+		}
+	}
+}
+
+func TestWebSocketFraming(t *testing.T) {
+	server := wsEchoServer(&WSUpgrader{})
+	defer server.Close()
+	resp, c := wsTestDial(t, server, "/echo")
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("got status %d", resp.StatusCode)
+	}
+
+	// 单帧文本消息
+	c.writeFrame(true, WSText, []byte("hello"))
+	if fin, opcode, payload := c.readFrame(t); !fin || opcode != WSText || string(payload) != "hello" {
+		t.Errorf("got fin=%v opcode=%d payload=%q", fin, opcode, payload)
+	}
+
+	// 分片消息中间插入ping
+	c.writeFrame(false, WSText, []byte("frag"))
+	c.writeFrame(true, WSPing, []byte("p1"))
+	c.writeFrame(false, wsContinuation, []byte("men"))
+	c.writeFrame(true, wsContinuation, []byte("ted"))
+	if _, opcode, payload := c.readFrame(t); opcode != WSPong || string(payload) != "p1" {
+		t.Errorf("got opcode=%d payload=%q, want pong p1", opcode, payload)
+	}
+	if _, opcode, payload := c.readFrame(t); opcode != WSText || string(payload) != "fragmented" {
+		t.Errorf("got opcode=%d payload=%q, want fragmented", opcode, payload)
+	}
+
+	// 使用16位和64位长度的二进制消息，服务端分片返回
+	for _, size := range []int{200, 70000} {
+		data := bytes.Repeat([]byte{0xab}, size)
+		c.writeFrame(true, WSBinary, data)
+		var got []byte
+		for i := 0; ; i++ {
+			fin, opcode, payload := c.readFrame(t)
+			wantOp := wsContinuation
+			if i == 0 {
+				wantOp = WSBinary
+			}
+			if opcode != wantOp {
+				t.Fatalf("size %d frame %d: got opcode %d, want %d", size, i, opcode, wantOp)
+			}
+			got = append(got, payload...)
+			if fin {
+				break
+			}
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("size %d: echoed %d bytes", size, len(got))
+		}
+	}
+
+	// 处理函数正常返回时以1000关闭
+	c.writeFrame(true, WSText, []byte("bye"))
+	if code := c.readClose(t); code != WSCloseNormal {
+		t.Errorf("got close code %d, want %d", code, WSCloseNormal)
+	}
+}
+
+func TestWebSocketClose(t *testing.T) {
+	server := wsEchoServer(&WSUpgrader{ReadLimit: 64})
+	defer server.Close()
+
+	closeFrame := func(code int, reason string) []byte {
+		return append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+	}
+	tests := []struct {
+		name  string
+		send  func(c *wsTestConn)
+		reply int
+	}{
+		{"client close", func(c *wsTestConn) { c.writeFrame(true, WSClose, closeFrame(WSCloseGoingAway, "bye")) }, WSCloseGoingAway},
+		{"empty close", func(c *wsTestConn) { c.writeFrame(true, WSClose, nil) }, WSCloseNormal},
+		{"invalid close code", func(c *wsTestConn) { c.writeFrame(true, WSClose, closeFrame(WSCloseNoStatus, "")) }, WSCloseProtocolError},
+		{"one byte close", func(c *wsTestConn) { c.writeFrame(true, WSClose, []byte{3}) }, WSCloseProtocolError},
+		{"unmasked", func(c *wsTestConn) { c.writeFrame(true, WSText, []byte("hi"), true) }, WSCloseProtocolError},
+		{"reserved bits", func(c *wsTestConn) { c.writeFrame(true, WSText|0x40, []byte("hi")) }, WSCloseProtocolError},
+		{"unknown opcode", func(c *wsTestConn) { c.writeFrame(true, 3, []byte("hi")) }, WSCloseProtocolError},
+		{"lone continuation", func(c *wsTestConn) { c.writeFrame(true, wsContinuation, []byte("hi")) }, WSCloseProtocolError},
+		{"interleaved message", func(c *wsTestConn) {
+			c.writeFrame(false, WSText, []byte("a"))
+			c.writeFrame(true, WSText, []byte("b"))
+		}, WSCloseProtocolError},
+		{"fragmented ping", func(c *wsTestConn) { c.writeFrame(false, WSPing, nil) }, WSCloseProtocolError},
+		{"long ping", func(c *wsTestConn) { c.writeFrame(true, WSPing, make([]byte, 126)) }, WSCloseProtocolError},
+		{"invalid utf-8", func(c *wsTestConn) { c.writeFrame(true, WSText, []byte{0xff, 0xfe}) }, WSCloseInvalidPayload},
+		{"too big", func(c *wsTestConn) { c.writeFrame(true, WSBinary, make([]byte, 65)) }, WSCloseMessageTooBig},
+		{"too big fragments", func(c *wsTestConn) {
+			c.writeFrame(false, WSBinary, make([]byte, 40))
+			c.writeFrame(true, wsContinuation, make([]byte, 40))
+		}, WSCloseMessageTooBig},
+		{"handler error", func(c *wsTestConn) { c.writeFrame(true, WSText, []byte("fail")) }, WSCloseInternalError},
+	}
+	for _, tt := range tests {
+		resp, c := wsTestDial(t, server, "/echo")
+		if resp.StatusCode != http.StatusSwitchingProtocols {
+			t.Fatalf("%s: got status %d", tt.name, resp.StatusCode)
+		}
+		tt.send(c)
+		if code := c.readClose(t); code != tt.reply {
+			t.Errorf("%s: got close code %d, want %d", tt.name, code, tt.reply)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package doris
+
+import (
+	"net/http"
+)
+
+// 将标准的http.Handler包装为HandlerFunc
+func WrapHandler(h http.Handler) HandlerFunc {
+	return func(c *Context) error {
+		h.ServeHTTP(c.Response, c.Request)
+		return nil
+	}
+}
+
+// 将标准的http.HandlerFunc包装为HandlerFunc
+func WrapHandlerFunc(f http.HandlerFunc) HandlerFunc {
+	return WrapHandler(f)
+}
+
+// 将func(http.Handler) http.Handler形式的标准中间件包装为HandlerFunc
+// 中间件调用内层handler时执行c.Next()继续处理链
+// 内层handler收到的请求和响应对象会替换上下文中的对象
+// 中间件没有调用内层handler时终止处理链
+func WrapMiddleware(m func(http.Handler) http.Handler) HandlerFunc {
+	return func(c *Context) error {
+		called := false
+		response := c.Response
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			c.Request = r
+			if w == http.ResponseWriter(response) {
+				c.Next()
+				return
+			}
+			// 中间件包装了响应对象（比如gzip），之后的处理链写入包装后的对象
+			// 包装对象最终写回原来的Response，因此需要使用新的Response
+			wrapped := &Response{}
+			wrapped.reset(w)
+			c.Response = wrapped
+			c.Next()
+			wrapped.finish()
+		})
+		m(next).ServeHTTP(response, c.Request)
+		// 恢复原始的响应对象
+		c.Response = response
+		if !called {
+			c.Abort()
+		}
+		return nil
+	}
+}
+
+// 将处理链包装为标准的http.Handler
+// 处理链中返回的错误交给集中式错误处理器
+func (doris *Doris) ToHandler(handlers ...HandlerFunc) http.Handler {
+	assert1(len(handlers) > 0, "there must be at least one handler")
+	chain := HandlersChain(handlers)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c := doris.pool.Get().(*Context)
+		c.Response.reset(w)
+		c.Request = req
+		c.handlers = chain
+		c.index = -1 // 默认设置为-1
+		c.Next()     // 执行函数处理链
+		c.Response.finish()
+		c.reset()
+		doris.pool.Put(c)
+	})
+}
@@ -0,0 +1,134 @@
+package doris
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type ctxKey string
+
+// gzip风格的中间件，使用包装后的响应对象压缩响应体
+type gzipWriter struct {
+	http.ResponseWriter
+	gz *gzip.Writer
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	return w.gz.Write(data)
+}
+
+func gzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		next.ServeHTTP(&gzipWriter{ResponseWriter: w, gz: gz}, r)
+	})
+}
+
+func TestWrapMiddlewareNext(t *testing.T) {
+	d := New()
+	after := false
+	d.Use(WrapMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Before", "yes")
+			r = r.WithContext(context.WithValue(r.Context(), ctxKey("user"), "alice"))
+			next.ServeHTTP(w, r)
+			after = true
+		})
+	}))
+	d.GET("/", func(c *Context) error {
+		c.String(http.StatusCreated, "%v", c.Request.Context().Value(ctxKey("user")))
+		return nil
+	})
+	w := performRequest(d, http.MethodGet, "/")
+	if w.Code != http.StatusCreated || w.Body.String() != "alice" {
+		t.Errorf("got %d %q, want 201 %q", w.Code, w.Body.String(), "alice")
+	}
+	if w.Header().Get("X-Before") != "yes" || !after {
+		t.Errorf("middleware did not run around the chain: header %q, after %v", w.Header().Get("X-Before"), after)
+	}
+}
+
+func TestWrapMiddlewareShortCircuit(t *testing.T) {
+	d := New()
+	reached := false
+	d.Use(WrapMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") == "" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}))
+	d.GET("/", func(c *Context) error {
+		reached = true
+		c.String(http.StatusOK, "secret")
+		return nil
+	})
+	w := performRequest(d, http.MethodGet, "/")
+	if w.Code != http.StatusUnauthorized || w.Body.String() != "unauthorized\n" || reached {
+		t.Errorf("got %d %q reached=%v, want aborted 401", w.Code, w.Body.String(), reached)
+	}
+	w = performRequest(d, http.MethodGet, "/", "Authorization", "token")
+	if w.Code != http.StatusOK || w.Body.String() != "secret" {
+		t.Errorf("got %d %q, want 200 %q", w.Code, w.Body.String(), "secret")
+	}
+}
+
+func TestWrapMiddlewareWrappedWriter(t *testing.T) {
+	d := New()
+	d.Use(WrapMiddleware(gzipMiddleware))
+	d.GET("/", func(c *Context) error {
+		c.String(http.StatusAccepted, "hello gzip")
+		return nil
+	})
+	w := performRequest(d, http.MethodGet, "/")
+	if w.Code != http.StatusAccepted || w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("got %d Content-Encoding %q", w.Code, w.Header().Get("Content-Encoding"))
+	}
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hello gzip" {
+		t.Errorf("got %q, want %q", body, "hello gzip")
+	}
+}
+
+func TestToHandlerError(t *testing.T) {
+	d := New()
+	errBoom := errors.New("boom")
+	var handled error
+	d.HTTPErrorHandler = func(err error, c *Context) {
+		handled = err
+		c.String(http.StatusTeapot, "handled")
+	}
+	reached := false
+	h := d.ToHandler(func(c *Context) error {
+		return errBoom
+	}, func(c *Context) error {
+		reached = true
+		return nil
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/", h)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !errors.Is(handled, errBoom) || reached {
+		t.Errorf("got handled %v reached=%v, want %v and aborted chain", handled, reached, errBoom)
+	}
+	if w.Code != http.StatusTeapot || w.Body.String() != "handled" {
+		t.Errorf("got %d %q, want 418 %q", w.Code, w.Body.String(), "handled")
+	}
+}